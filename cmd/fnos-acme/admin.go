@@ -0,0 +1,261 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// adminAPI serves the authenticated http api for internal tooling,
+// actions are executed by the run loop like the control socket.
+type adminAPI struct {
+	ctx      context.Context
+	token    string
+	dataDir  string
+	requests chan<- controlRequest
+}
+
+func newAdminHandler(ctx context.Context, token, dataDir string, requests chan<- controlRequest) http.Handler {
+	a := &adminAPI{
+		ctx:      ctx,
+		token:    token,
+		dataDir:  dataDir,
+		requests: requests,
+	}
+
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/groups", a.groups)
+	api.HandleFunc("GET /api/v1/targets", a.targets)
+	api.HandleFunc("POST /api/v1/check", a.action(controlCheck))
+	api.HandleFunc("POST /api/v1/groups/{group}/renew", a.action(controlRenew))
+	api.HandleFunc("POST /api/v1/groups/{group}/deploy", a.action(controlDeploy))
+	api.HandleFunc("GET /api/v1/groups/{group}/chain", a.chain)
+	api.HandleFunc("GET /api/v1/history", a.history)
+
+	// the dashboard is static, it asks for the token and calls the api
+	mux := http.NewServeMux()
+	mux.Handle("/api/", a.authenticate(api))
+	mux.Handle("/", dashboardHandler())
+
+	return mux
+}
+
+// authenticate checks the bearer token of every request.
+func (a *adminAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			slog.Warn("admin api unauthorized", "remote", r.RemoteAddr, "path", r.URL.Path)
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fnos-acme"`)
+			writeJSON(w, http.StatusUnauthorized, controlReply{Error: "unauthorized"})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *adminAPI) status(w http.ResponseWriter, r *http.Request) (*daemonStatus, bool) {
+	reply, status, ok := submitControl(a.ctx, r, a.requests, controlRequest{Op: controlStatus})
+	if !ok {
+		return nil, false
+	}
+
+	if reply.Error != "" {
+		writeJSON(w, status, reply)
+		return nil, false
+	}
+
+	return reply.Status, true
+}
+
+func (a *adminAPI) groups(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.status(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificates":    s.Certificates,
+		"pendingApproval": s.Pending,
+		"outbox":          s.Outbox,
+		"nextCheck":       s.NextCheck,
+	})
+}
+
+func (a *adminAPI) targets(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.status(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"targets": s.Targets})
+}
+
+func (a *adminAPI) action(op string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := controlRequest{
+			Op:     op,
+			Group:  r.PathValue("group"),
+			Target: r.URL.Query().Get("target"),
+		}
+
+		if reply, status, ok := submitControl(a.ctx, r, a.requests, req); ok {
+			writeJSON(w, status, reply)
+		}
+	}
+}
+
+// chain downloads the public certificate chain of the group, private keys are never served.
+func (a *adminAPI) chain(w http.ResponseWriter, r *http.Request) {
+	certs, err := listManagedCertificates(a.dataDir)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, controlReply{Error: err.Error()})
+		return
+	}
+
+	group := r.PathValue("group")
+
+	idx := slices.IndexFunc(certs, func(c cert) bool { return c.name == group })
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, controlReply{Error: fmt.Sprintf("%s %s", errUnknownGroup, group)})
+		return
+	}
+
+	// the stored file is not served as is, it may be a combined PEM holding the key
+	chain, err := certcrypto.ParsePEMBundle(certs[idx].rawCert)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, controlReply{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.crt"`, strings.ReplaceAll(group, "*", "_")))
+	_, _ = w.Write(encodeCertificates(chain))
+}
+
+func (a *adminAPI) history(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	since, err := parseHistoryTime(q.Get("since"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, controlReply{Error: err.Error()})
+		return
+	}
+
+	until, err := parseHistoryTime(q.Get("until"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, controlReply{Error: err.Error()})
+		return
+	}
+
+	entries, err := openJournal(a.dataDir).entries()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, controlReply{Error: err.Error()})
+		return
+	}
+
+	entries = journalFilter{
+		Group: q.Get("group"),
+		NAS:   q.Get("nas"),
+		Type:  q.Get("type"),
+		Since: since,
+		Until: until,
+	}.apply(entries)
+
+	// limit keeps the latest entries
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			writeJSON(w, http.StatusBadRequest, controlReply{Error: fmt.Sprintf("invalid limit %q", v)})
+			return
+		}
+
+		entries = entries[max(len(entries)-limit, 0):]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+// ownCertificate serves the admin api with the acme certificate of the group, reloaded after renewal.
+type ownCertificate struct {
+	dataDir string
+	group   string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	loadedAt time.Time
+}
+
+// ownCertificateReload is how often the certificate files are read again.
+const ownCertificateReload = time.Minute
+
+func (o *ownCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.cert != nil && time.Since(o.loadedAt) < ownCertificateReload {
+		return o.cert, nil
+	}
+
+	certs, err := listCertificates(o.dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(certs, func(c cert) bool { return c.name == o.group })
+	if idx < 0 {
+		if o.cert != nil {
+			return o.cert, nil
+		}
+
+		return nil, errors.New("certificate of " + o.group + " has not been obtained yet")
+	}
+
+	pair, err := tls.X509KeyPair(certs[idx].rawCert, certs[idx].rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	o.cert = &pair
+	o.loadedAt = time.Now()
+
+	return o.cert, nil
+}
+
+// adminTLSConfig uses the given key pair, or the own certificate of the group if not given.
+func adminTLSConfig(certFile, keyFile, dataDir, group string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile == "" && keyFile == "" {
+		config.GetCertificate = (&ownCertificate{dataDir: dataDir, group: group}).GetCertificate
+		return config, nil
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Certificates = []tls.Certificate{pair}
+
+	return config, nil
+}
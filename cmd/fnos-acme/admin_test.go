@@ -0,0 +1,107 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+const testAdminToken = "admin-token"
+
+// getChain requests the chain of the group from the admin api.
+func getChain(t *testing.T, dataDir, group string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/groups/"+group+"/chain", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	rec := httptest.NewRecorder()
+	newAdminHandler(context.Background(), testAdminToken, dataDir, nil).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAdminChainOmitsPrivateKey(t *testing.T) {
+	const group = "imported.example.com"
+
+	certPEM, keyPEM := newTestCertificate(t, group, time.Now().Add(30*24*time.Hour))
+	combined := append(append([]byte{}, keyPEM...), certPEM...)
+
+	t.Run("imported", func(t *testing.T) {
+		dataDir := t.TempDir()
+
+		base := filepath.Join(t.TempDir(), "drop")
+		if err := os.WriteFile(base+pemExt, combined, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		certData, keyData, err := readImportPair(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := validateImport(certData, keyData)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := saveImportedCertificate(dataDir, &certificate.Resource{
+			Domain:      c.name,
+			Certificate: c.rawCert,
+			PrivateKey:  c.rawKey,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		assertChain(t, getChain(t, dataDir, group), certPEM)
+	})
+
+	// stored by a version which kept the imported file as is, the certificate comes first to be listed
+	t.Run("stored combined", func(t *testing.T) {
+		dataDir := t.TempDir()
+
+		if err := writeCertificate(importedDir(dataDir), &certificate.Resource{
+			Domain:      group,
+			Certificate: append(append([]byte{}, certPEM...), keyPEM...),
+			PrivateKey:  keyPEM,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		assertChain(t, getChain(t, dataDir, group), certPEM)
+	})
+}
+
+func assertChain(t *testing.T, rec *httptest.ResponseRecorder, want []byte) {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	if bytes.Contains(rec.Body.Bytes(), []byte("PRIVATE KEY")) {
+		t.Fatalf("chain serves the private key:\n%s", rec.Body)
+	}
+
+	if !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Fatalf("chain = %s, want %s", rec.Body, want)
+	}
+}
+
+func TestAdminChainUnknownGroup(t *testing.T) {
+	if rec := getChain(t, t.TempDir(), "missing.example.com"); rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+func commandPending() *cli.Command {
+	return &cli.Command{
+		Name:   "pending",
+		Usage:  "list certificates waiting for approval",
+		Action: pending,
+	}
+}
+
+func commandApprove() *cli.Command {
+	return &cli.Command{
+		Name:      "approve",
+		Usage:     "approve the pending certificate of a group and deploy it by the running daemon",
+		ArgsUsage: "<group>",
+		Action:    approve,
+	}
+}
+
+func pending(ctx context.Context, c *cli.Command) error {
+	pendings, err := listPendingCertificates(c.String(flgDataDir))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tDOMAINS\tISSUED\tNOT AFTER")
+
+	for _, p := range pendings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			p.name,
+			strings.Join(p.DNSNames, ","),
+			p.issuedAt.Format(time.RFC3339),
+			p.NotAfter.Local().Format(time.RFC3339),
+		)
+	}
+
+	return w.Flush()
+}
+
+func approve(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("must specific group")
+	}
+
+	if err := approveCertificate(c.String(flgDataDir), c.Args().First()); err != nil {
+		return err
+	}
+
+	openJournal(c.String(flgDataDir)).record(journalEntry{
+		Type:   journalCertApproved,
+		Group:  c.Args().First(),
+		Detail: map[string]string{"by": "manual"},
+	})
+
+	// the approved certificate is pushed even if fnos does not report the expiry of its copy
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/deploy", &controlRequest{Group: c.Args().First()})
+	if errors.Is(err, errDaemonNotRunning) {
+		ob, err := loadOutbox(c.String(flgDataDir))
+		if err != nil {
+			return err
+		}
+
+		if err := ob.add(c.Args().First(), true, errors.New("approved while the daemon is not running")); err != nil {
+			return err
+		}
+
+		fmt.Printf("certificate %s approved, deployment queued until the daemon starts\n", c.Args().First())
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
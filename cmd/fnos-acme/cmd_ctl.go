@@ -0,0 +1,275 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgTarget = "target"
+)
+
+var errDaemonNotRunning = errors.New("daemon is not running")
+
+// commandCtl talks to the running daemon through the control socket in the data dir.
+func commandCtl() *cli.Command {
+	return &cli.Command{
+		Name:  "ctl",
+		Usage: "control the running daemon",
+		Commands: []*cli.Command{
+			{
+				Name:   "status",
+				Usage:  "show the certificates and pending deployments of the daemon",
+				Action: ctlStatus,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  flgJSON,
+						Usage: "print status as json",
+					},
+				},
+			},
+			{
+				Name:   "check",
+				Usage:  "check the certificates and sync them to the NAS now",
+				Action: ctlCheck,
+			},
+			{
+				Name:      "renew",
+				Usage:     "obtain a new certificate of the group now",
+				ArgsUsage: "<group>",
+				Action:    ctlRenew,
+			},
+			{
+				Name:   "reload",
+				Usage:  "re-read the config file and the certificates in the data dir and sync them to the NAS",
+				Action: ctlReload,
+			},
+			{
+				Name:      "deploy",
+				Usage:     "deploy the certificate of the group to the NAS now",
+				ArgsUsage: "<group>",
+				Action:    ctlDeploy,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  flgTarget,
+						Usage: "NAS address to deploy to, defaults to the NAS of the daemon",
+					},
+				},
+			},
+			{
+				Name:      "rollback",
+				Usage:     "restore the previous certificate of the group and deploy it to the NAS",
+				ArgsUsage: "<group>",
+				Action:    ctlRollback,
+			},
+		},
+	}
+}
+
+// controlClient sends requests to the control socket.
+type controlClient struct {
+	client *http.Client
+}
+
+func newControlClient(dataDir string) *controlClient {
+	path := controlSocketPath(dataDir)
+
+	return &controlClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+func (c *controlClient) do(ctx context.Context, method, path string, req *controlRequest) (*controlReply, error) {
+	var body bytes.Buffer
+
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, "http://fnos-acme"+path, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("%w: %w", errDaemonNotRunning, err)
+		}
+
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var reply controlReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("%s: %w", resp.Status, err)
+	}
+
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return &reply, nil
+}
+
+func ctlStatus(ctx context.Context, c *cli.Command) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodGet, "/v1/status", nil)
+	if err != nil {
+		return err
+	}
+
+	s := reply.Status
+
+	if c.Bool(flgJSON) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(s)
+	}
+
+	fmt.Printf("version:    %s\n", s.Version)
+	fmt.Printf("nas:        %s\n", s.NAS)
+	fmt.Printf("next check: %s\n", s.NextCheck.Local().Format(time.RFC3339))
+
+	for _, p := range s.Pending {
+		fmt.Printf("pending:    %s is waiting for approval\n", p)
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSOURCE\tEXPIRES\tDAYS\tNAS STATE\tNAS CERT\tLAST ERROR")
+
+	for _, cert := range s.Certificates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			cert.Group,
+			cert.Source,
+			cert.NotAfter.Local().Format(time.RFC3339),
+			cert.DaysLeft(),
+			cert.NASState,
+			cert.NASCert,
+			orDash(cert.LastError),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(s.Outbox) > 0 {
+		fmt.Println()
+
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PENDING DEPLOYMENT\tATTEMPTS\tNEXT ATTEMPT\tLAST ERROR")
+
+		for _, op := range s.Outbox {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", op.Group, op.Attempts, op.NextAttempt.Local().Format(time.RFC3339), orDash(op.LastError))
+		}
+
+		return w.Flush()
+	}
+
+	return nil
+}
+
+func ctlCheck(ctx context.Context, c *cli.Command) error {
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/check", nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
+
+func ctlRenew(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("must specific the group to renew")
+	}
+
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/renew", &controlRequest{Group: c.Args().First()})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
+
+func ctlReload(ctx context.Context, c *cli.Command) error {
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/reload", nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
+
+func ctlDeploy(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("must specific the group to deploy")
+	}
+
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/deploy", &controlRequest{
+		Group:  c.Args().First(),
+		Target: c.String(flgTarget),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
+
+func ctlRollback(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("must specific the group to roll back")
+	}
+
+	reply, err := newControlClient(c.String(flgDataDir)).do(ctx, http.MethodPost, "/v1/rollback", &controlRequest{Group: c.Args().First()})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(reply.Message)
+
+	return nil
+}
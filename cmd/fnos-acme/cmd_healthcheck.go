@@ -0,0 +1,86 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgProbe = "probe"
+)
+
+// commandHealthcheck probes the running daemon, for the container healthcheck of distroless image.
+func commandHealthcheck() *cli.Command {
+	return &cli.Command{
+		Name:   "healthcheck",
+		Usage:  "probe the health endpoint of the running daemon",
+		Before: applyConfigFile,
+		Action: healthcheck,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    flgListen,
+				Value:   "",
+				Usage:   "address the daemon serves on",
+				Sources: cli.EnvVars("LISTEN"),
+			},
+			&cli.StringFlag{
+				Name:  flgProbe,
+				Value: "healthz",
+				Usage: "probe to check, healthz or readyz",
+			},
+		},
+	}
+}
+
+func healthcheck(ctx context.Context, c *cli.Command) error {
+	if c.String(flgListen) == "" {
+		return fmt.Errorf("must specific LISTEN")
+	}
+
+	host, port, err := net.SplitHostPort(c.String(flgListen))
+	if err != nil {
+		return err
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s/%s", net.JoinHostPort(host, port), c.String(flgProbe))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+
+	fmt.Print(string(body))
+
+	return nil
+}
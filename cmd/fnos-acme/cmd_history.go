@@ -0,0 +1,152 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgGroup  = "group"
+	flgNAS    = "nas"
+	flgSince  = "since"
+	flgUntil  = "until"
+	flgType   = "type"
+	flgVerify = "verify"
+	flgJSON   = "json"
+)
+
+func commandHistory() *cli.Command {
+	return &cli.Command{
+		Name:   "history",
+		Usage:  "query the audit journal",
+		Action: history,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  flgGroup,
+				Usage: "only entries of the group",
+			},
+			&cli.StringFlag{
+				Name:  flgNAS,
+				Usage: "only entries of the NAS address",
+			},
+			&cli.StringFlag{
+				Name:  flgSince,
+				Usage: "only entries after the time, RFC3339 or duration ago like 24h",
+			},
+			&cli.StringFlag{
+				Name:  flgUntil,
+				Usage: "only entries before the time, RFC3339 or duration ago like 24h",
+			},
+			&cli.StringFlag{
+				Name:  flgType,
+				Usage: "only entries of the type or type prefix, e.g. nas",
+			},
+			&cli.BoolFlag{
+				Name:  flgVerify,
+				Usage: "verify the hash chain of the whole journal",
+			},
+			&cli.BoolFlag{
+				Name:  flgJSON,
+				Usage: "print entries as json lines",
+			},
+		},
+	}
+}
+
+// parseHistoryTime parses RFC3339 time or duration before now.
+func parseHistoryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be RFC3339 or duration", v)
+	}
+
+	return t, nil
+}
+
+func history(ctx context.Context, c *cli.Command) error {
+	since, err := parseHistoryTime(c.String(flgSince))
+	if err != nil {
+		return err
+	}
+
+	until, err := parseHistoryTime(c.String(flgUntil))
+	if err != nil {
+		return err
+	}
+
+	entries, err := openJournal(c.String(flgDataDir)).entries()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flgVerify) {
+		if err := verifyJournal(entries); err != nil {
+			return fmt.Errorf("journal is tampered: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "journal verified, %d entries\n", len(entries))
+	}
+
+	entries = journalFilter{
+		Group: c.String(flgGroup),
+		NAS:   c.String(flgNAS),
+		Type:  c.String(flgType),
+		Since: since,
+		Until: until,
+	}.apply(entries)
+
+	if c.Bool(flgJSON) {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tTIME\tTYPE\tGROUP\tNAS\tNAS CERT\tDETAIL")
+
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			e.Seq,
+			e.Time.Local().Format(time.RFC3339),
+			e.Type,
+			orDash(e.Group),
+			orDash(e.NAS),
+			e.NASCertID,
+			e.detailString(),
+		)
+	}
+
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
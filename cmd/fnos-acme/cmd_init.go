@@ -0,0 +1,451 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+const (
+	flgFile = "file"
+)
+
+// missingEnvPattern matches the error of lego providers on missing credentials.
+var missingEnvPattern = regexp.MustCompile(`credentials information are missing: ([A-Z0-9_,]+)`)
+
+func commandInit() *cli.Command {
+	return &cli.Command{
+		Name:   "init",
+		Usage:  "ask for the settings, verify them and write a config file",
+		Action: initConfig,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  flgFile,
+				Value: "fnos-acme.conf",
+				Usage: "config file to write",
+			},
+		},
+	}
+}
+
+// prompter reads the answers line by line, secrets are not echoed on a terminal.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// ask returns the answer or def if the answer is empty, it asks again if both are empty.
+func (p *prompter) ask(label, def string) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", label)
+		}
+
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+
+		if answer == "" {
+			answer = def
+		}
+
+		if answer != "" {
+			return answer, nil
+		}
+
+		fmt.Fprintln(p.out, "required")
+	}
+}
+
+func (p *prompter) askSecret(label, def string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if def != "" {
+			label += " (empty to keep)"
+		}
+
+		fmt.Fprintf(p.out, "%s: ", label)
+
+		answer, err := p.readLine()
+		if answer == "" {
+			answer = def
+		}
+
+		return answer, err
+	}
+
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s (empty to keep): ", label)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", label)
+		}
+
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(p.out)
+
+		if err != nil {
+			return "", err
+		}
+
+		answer := strings.TrimSpace(string(data))
+		if answer == "" {
+			answer = def
+		}
+
+		if answer != "" {
+			return answer, nil
+		}
+
+		fmt.Fprintln(p.out, "required")
+	}
+}
+
+func (p *prompter) confirm(label string) (bool, error) {
+	fmt.Fprintf(p.out, "%s [y/N]: ", label)
+
+	answer, err := p.readLine()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes"), nil
+}
+
+// initSettings are the answers of the wizard.
+type initSettings struct {
+	address     string
+	username    string
+	password    string
+	domains     []string
+	email       string
+	provider    string
+	providerEnv []configValue
+	tosAgreed   bool
+}
+
+func initConfig(ctx context.Context, c *cli.Command) error {
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+
+	path := c.String(flgFile)
+	if _, err := os.Stat(path); err == nil {
+		overwrite, err := p.confirm(fmt.Sprintf("%s exists, overwrite", path))
+		if err != nil {
+			return err
+		}
+
+		if !overwrite {
+			return nil
+		}
+	}
+
+	s, err := askSettings(c, p)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out)
+
+	checks := []struct {
+		name string
+		run  func(context.Context, *initSettings) error
+	}{
+		{"fnos login", checkNASLogin},
+		{"dns provider", checkDNSProvider},
+		{"acme staging registration", checkACMERegistration},
+	}
+
+	failed := 0
+
+	for _, check := range checks {
+		fmt.Fprintf(p.out, "checking %s... ", check.name)
+
+		if err := check.run(ctx, s); err != nil {
+			fmt.Fprintf(p.out, "failed: %s\n", err)
+			failed++
+
+			continue
+		}
+
+		fmt.Fprintln(p.out, "ok")
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed, config not written", failed, len(checks))
+	}
+
+	values := []configValue{
+		{"FNOS_ADDRESS", s.address},
+		{"FNOS_USERNAME", s.username},
+		{"FNOS_PASSWORD", s.password},
+		{"DOMAINS", strings.Join(s.domains, ",")},
+		{"EMAIL", s.email},
+		{"ACME_TERM_OF_SERVICE_AGREED", "true"},
+		{"DNS_PROVIDER", s.provider},
+	}
+
+	header := fmt.Sprintf("generated by fnos-acme init at %s\nrun with CONFIG=%s fnos-acme run", time.Now().Format(time.RFC3339), path)
+
+	if err := writeConfigFile(path, header, append(values, s.providerEnv...)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.out, "\nconfig written to %s\n", path)
+
+	return nil
+}
+
+// askSettings asks for the settings, the current values from the flags, environment or config file are the defaults.
+func askSettings(c *cli.Command, p *prompter) (*initSettings, error) {
+	s := &initSettings{}
+
+	var err error
+
+	for {
+		if s.address, err = p.ask("fnOS address, e.g. http://192.168.1.2:5666", c.String(flgFnosAddress)); err != nil {
+			return nil, err
+		}
+
+		if u, err := url.Parse(s.address); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			break
+		}
+
+		fmt.Fprintln(p.out, "must be a http or https url")
+	}
+
+	if s.username, err = p.ask("fnOS username", c.String(flgFnosUsername)); err != nil {
+		return nil, err
+	}
+
+	if s.password, err = p.askSecret("fnOS password", c.String(flgFnosPassword)); err != nil {
+		return nil, err
+	}
+
+	domains, err := p.ask("domains, comma separated, the first one names the certificate", strings.Join(c.StringSlice(flgDomains), ","))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			s.domains = append(s.domains, domain)
+		}
+	}
+
+	if s.email, err = p.ask("acme account email", c.String(flgEmail)); err != nil {
+		return nil, err
+	}
+
+	for {
+		if s.provider, err = p.ask("dns provider, see https://go-acme.github.io/lego/dns/", c.String(flgDnsProvider)); err != nil {
+			return nil, err
+		}
+
+		if _, err := dns.NewDNSChallengeProviderByName(s.provider); err == nil || !strings.Contains(err.Error(), "unrecognized DNS provider") {
+			break
+		}
+
+		fmt.Fprintf(p.out, "unknown dns provider %s\n", s.provider)
+	}
+
+	if err := askProviderEnv(p, s); err != nil {
+		return nil, err
+	}
+
+	if s.tosAgreed, err = p.confirm("agree to the terms of service of the acme CA"); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// askProviderEnv asks for the credentials reported missing by the provider, then for any other variables of it.
+// The answers are set in the environment, where the provider reads them.
+func askProviderEnv(p *prompter, s *initSettings) error {
+	set := func(key, value string) error {
+		s.providerEnv = slices.DeleteFunc(s.providerEnv, func(v configValue) bool { return v.key == key })
+		s.providerEnv = append(s.providerEnv, configValue{key, value})
+
+		return os.Setenv(key, value)
+	}
+
+	asked := make(map[string]bool)
+
+	for {
+		_, err := dns.NewDNSChallengeProviderByName(s.provider)
+		if err == nil {
+			break
+		}
+
+		m := missingEnvPattern.FindStringSubmatch(err.Error())
+		if m == nil {
+			// not a missing credential, it is reported by the check
+			break
+		}
+
+		keys := strings.Split(m[1], ",")
+		if slices.ContainsFunc(keys, func(key string) bool { return asked[key] }) {
+			break
+		}
+
+		for _, key := range keys {
+			asked[key] = true
+
+			var value string
+
+			if isSecretEnv(key) {
+				value, err = p.askSecret(key, "")
+			} else {
+				value, err = p.ask(key, "")
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if err := set(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(p.out, "other variables of %s, KEY=VALUE, empty to finish\n", s.provider)
+
+	for {
+		fmt.Fprint(p.out, "> ")
+
+		line, err := p.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if line == "" {
+			return nil
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			fmt.Fprintln(p.out, "must be KEY=VALUE")
+			continue
+		}
+
+		if err := set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+}
+
+func isSecretEnv(key string) bool {
+	for _, s := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkNASLogin(ctx context.Context, s *initSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, err := trim.NewMainClient(s.address, trim.WithLogin(s.username, s.password))
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	// listing the certificates checks the permission to manage them as well
+	_, err = client.Main().RemoteAccessService().GetCertList(ctx)
+
+	return err
+}
+
+// checkDNSProvider creates and deletes a test TXT record of the challenge of the first domain.
+func checkDNSProvider(_ context.Context, s *initSettings) error {
+	if len(s.domains) == 0 {
+		return fmt.Errorf("must specific DOMAINS")
+	}
+
+	provider, err := dns.NewDNSChallengeProviderByName(s.provider)
+	if err != nil {
+		return err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	domain := strings.TrimPrefix(s.domains[0], "*.")
+	keyAuth := "fnos-acme-init." + hex.EncodeToString(token)
+
+	if err := provider.Present(domain, hex.EncodeToString(token), keyAuth); err != nil {
+		return fmt.Errorf("create record: %w", err)
+	}
+
+	if err := provider.CleanUp(domain, hex.EncodeToString(token), keyAuth); err != nil {
+		return fmt.Errorf("delete record: %w", err)
+	}
+
+	return nil
+}
+
+// checkACMERegistration registers a throwaway account against the staging CA, the account is not saved.
+func checkACMERegistration(_ context.Context, s *initSettings) error {
+	if !s.tosAgreed {
+		return errors.New("terms of service not agreed")
+	}
+
+	acc, err := createAccount(s.email)
+	if err != nil {
+		return err
+	}
+
+	config := lego.NewConfig(acc)
+	config.CADirURL = lego.LEDirectoryStaging
+	config.UserAgent = userAgent
+
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+
+	return err
+}
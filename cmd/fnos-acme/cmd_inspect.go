@@ -0,0 +1,423 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim/api/remoteaccess"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgKey        = "key"
+	flgTLSAddress = "tls-address"
+)
+
+const nasPrefix = "nas:"
+
+// fnosHTTPSPort is the https port of the fnos web UI.
+const fnosHTTPSPort = "5667"
+
+func commandInspect() *cli.Command {
+	return &cli.Command{
+		Name:      "inspect",
+		Usage:     "show the chain, key and fingerprints of a certificate",
+		ArgsUsage: "<group|file|nas:ID>",
+		Action:    inspect,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  flgKey,
+				Usage: "private key file to match, for a file without the key",
+			},
+			&cli.StringFlag{
+				Name:  flgTLSAddress,
+				Usage: "host:port to fetch the certificate of nas:ID from, default to the https port of FNOS_ADDRESS",
+			},
+			outputFlag(),
+		},
+	}
+}
+
+// certDetails is the inspection of a certificate chain.
+type certDetails struct {
+	Source      string        `json:"source"`
+	Subject     string        `json:"subject"`
+	SANs        []string      `json:"sans"`
+	Serial      string        `json:"serial"`
+	NotBefore   time.Time     `json:"notBefore"`
+	NotAfter    time.Time     `json:"notAfter"`
+	KeyType     string        `json:"keyType"`
+	SHA256      string        `json:"sha256"`
+	SPKISHA256  string        `json:"spkiSha256"`
+	ARICertID   string        `json:"ariCertId,omitempty"`
+	KeyMatch    *bool         `json:"keyMatch,omitempty"`
+	KeyError    string        `json:"keyError,omitempty"`
+	Chain       []chainDetail `json:"chain"`
+	ChainIssues []string      `json:"chainIssues,omitempty"`
+}
+
+type chainDetail struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	Serial   string    `json:"serial"`
+	NotAfter time.Time `json:"notAfter"`
+	KeyType  string    `json:"keyType"`
+	SHA256   string    `json:"sha256"`
+}
+
+func inspect(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("must specific a group, a file or nas:ID")
+	}
+
+	source, chain, key, err := loadInspectTarget(ctx, c, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if keyFile := c.String(flgKey); keyFile != "" {
+		if key, err = os.ReadFile(keyFile); err != nil {
+			return err
+		}
+	}
+
+	details := inspectChain(source, chain, key)
+
+	if c.String(flgOutput) == outputJSON {
+		return printJSON(details)
+	}
+
+	printCertDetails(details)
+
+	return nil
+}
+
+// loadInspectTarget returns the certificate chain and the private key, if known, of the target.
+// The target is a certificate on the NAS if prefixed with nas:, an existing file, or a group in the data dir.
+func loadInspectTarget(ctx context.Context, c *cli.Command, target string) (string, []*x509.Certificate, []byte, error) {
+	if id, ok := strings.CutPrefix(target, nasPrefix); ok {
+		chain, err := fetchRemoteChain(ctx, c, id)
+		return target, chain, nil, err
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		chain, err := certcrypto.ParsePEMBundle(data)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("%s: %w", target, err)
+		}
+
+		return target, chain, findPEMPrivateKey(data), nil
+	}
+
+	dataDir := c.String(flgDataDir)
+
+	managed, err := listManagedCertificates(dataDir)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	pendings, err := listPendingCertificates(dataDir)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	for _, p := range pendings {
+		managed = append(managed, p.cert)
+	}
+
+	for _, mc := range managed {
+		if mc.name != target {
+			continue
+		}
+
+		chain, err := certcrypto.ParsePEMBundle(mc.rawCert)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		return filepath.Join(mc.dir, mc.name+certExt), chain, mc.rawKey, nil
+	}
+
+	return "", nil, nil, fmt.Errorf("no certificate for %s, neither a group nor a file", target)
+}
+
+// findPEMPrivateKey returns the first private key block in data.
+func findPEMPrivateKey(data []byte) []byte {
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return nil
+		}
+
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return pem.EncodeToMemory(block)
+		}
+	}
+}
+
+// fetchRemoteChain pulls the certificate of the NAS by a TLS handshake with its domain, as fnos does not
+// export the uploaded certificates. It fails if the NAS serves another certificate for the domain.
+func fetchRemoteChain(ctx context.Context, c *cli.Command, id string) ([]*x509.Certificate, error) {
+	certID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nas cert id %q", id)
+	}
+
+	nas := c.String(flgFnosAddress)
+	if nas == "" {
+		return nil, fmt.Errorf("must specific FNOS_ADDRESS")
+	}
+
+	remoteCerts, err := listRemoteCerts(ctx, c, nas)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(remoteCerts, func(rc remoteaccess.Cert) bool { return rc.ID == certID })
+	if idx < 0 {
+		return nil, fmt.Errorf("no cert %d on %s", certID, nas)
+	}
+
+	remoteCert := &remoteCerts[idx]
+
+	address := c.String(flgTLSAddress)
+	if address == "" {
+		if address, err = nasTLSAddress(nas); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+		Config: &tls.Config{
+			ServerName: strings.TrimPrefix(remoteCert.Domain, "*."),
+			// the chain is inspected, not trusted
+			InsecureSkipVerify: true,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("fetch cert %d from %s: %w", certID, address, err)
+	}
+
+	defer conn.Close()
+
+	chain := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate served by %s", address)
+	}
+
+	diff := remoteCertValidTo(remoteCert).Sub(chain[0].NotAfter)
+	if diff > time.Minute || diff < -time.Minute || !domainsEqual(chain[0].DNSNames, splitSAN(remoteCert.San)) {
+		return nil, fmt.Errorf("%s serves another certificate for %s than cert %d", address, remoteCert.Domain, certID)
+	}
+
+	return chain, nil
+}
+
+// nasTLSAddress is the https host:port of the NAS.
+func nasTLSAddress(nas string) (string, error) {
+	u, err := url.Parse(nas)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "https" {
+		if u.Port() != "" {
+			return u.Host, nil
+		}
+
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	}
+
+	return net.JoinHostPort(u.Hostname(), fnosHTTPSPort), nil
+}
+
+func inspectChain(source string, chain []*x509.Certificate, key []byte) *certDetails {
+	leaf := chain[0]
+
+	spki := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+
+	d := &certDetails{
+		Source:     source,
+		Subject:    leaf.Subject.String(),
+		SANs:       leaf.DNSNames,
+		Serial:     leaf.SerialNumber.Text(16),
+		NotBefore:  leaf.NotBefore,
+		NotAfter:   leaf.NotAfter,
+		KeyType:    publicKeyType(leaf.PublicKey),
+		SHA256:     certFingerprint(leaf),
+		SPKISHA256: base64.StdEncoding.EncodeToString(spki[:]),
+	}
+
+	for _, ip := range leaf.IPAddresses {
+		d.SANs = append(d.SANs, ip.String())
+	}
+
+	// the ARI ID needs the authority key id, which self-signed certificates may not have
+	if id, err := certificate.MakeARICertID(leaf); err == nil && len(leaf.AuthorityKeyId) > 0 {
+		d.ARICertID = id
+	}
+
+	if key != nil {
+		match, err := keyMatches(leaf, key)
+		if err != nil {
+			d.KeyError = err.Error()
+		} else {
+			d.KeyMatch = &match
+		}
+	}
+
+	for i, cert := range chain {
+		d.Chain = append(d.Chain, chainDetail{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			Serial:   cert.SerialNumber.Text(16),
+			NotAfter: cert.NotAfter,
+			KeyType:  publicKeyType(cert.PublicKey),
+			SHA256:   certFingerprint(cert),
+		})
+
+		if i+1 < len(chain) {
+			if err := cert.CheckSignatureFrom(chain[i+1]); err != nil {
+				d.ChainIssues = append(d.ChainIssues, fmt.Sprintf("%s is not signed by %s: %s",
+					cert.Subject, chain[i+1].Subject, err))
+			}
+		}
+	}
+
+	if len(chain) == 1 && leaf.CheckSignatureFrom(leaf) != nil {
+		d.ChainIssues = append(d.ChainIssues, "issuer certificate is missing")
+	}
+
+	return d
+}
+
+func keyMatches(cert *x509.Certificate, key []byte) (bool, error) {
+	privateKey, err := certcrypto.ParsePEMPrivateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return false, errors.New("unsupported private key")
+	}
+
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false, errors.New("unsupported public key")
+	}
+
+	return pub.Equal(cert.PublicKey), nil
+}
+
+func publicKeyType(key any) string {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", key)
+	}
+}
+
+// certFingerprint is the colon separated SHA-256 of the certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+
+	hexes := make([]string, len(sum))
+	for i, b := range sum {
+		hexes[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexes, ":")
+}
+
+func printCertDetails(d *certDetails) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	keyMatch := "-"
+	switch {
+	case d.KeyError != "":
+		keyMatch = "error: " + d.KeyError
+	case d.KeyMatch != nil && *d.KeyMatch:
+		keyMatch = "yes"
+	case d.KeyMatch != nil:
+		keyMatch = "NO"
+	}
+
+	validity := fmt.Sprintf("%s - %s", d.NotBefore.Local().Format(time.RFC3339), d.NotAfter.Local().Format(time.RFC3339))
+	if left := time.Until(d.NotAfter); left > 0 {
+		validity += fmt.Sprintf(" (%d days left)", int(left.Hours()/24))
+	} else {
+		validity += " (expired)"
+	}
+
+	fmt.Fprintf(w, "Source:\t%s\n", d.Source)
+	fmt.Fprintf(w, "Subject:\t%s\n", d.Subject)
+	fmt.Fprintf(w, "SANs:\t%s\n", strings.Join(d.SANs, ", "))
+	fmt.Fprintf(w, "Serial:\t%s\n", d.Serial)
+	fmt.Fprintf(w, "Validity:\t%s\n", validity)
+	fmt.Fprintf(w, "Key:\t%s\n", d.KeyType)
+	fmt.Fprintf(w, "Key match:\t%s\n", keyMatch)
+	fmt.Fprintf(w, "SHA-256:\t%s\n", d.SHA256)
+	fmt.Fprintf(w, "SPKI SHA-256:\t%s\n", d.SPKISHA256)
+
+	if d.ARICertID != "" {
+		fmt.Fprintf(w, "ARI cert ID:\t%s\n", d.ARICertID)
+	}
+
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println("Chain:")
+
+	for i, cd := range d.Chain {
+		fmt.Printf("  %d: %s\n", i, cd.Subject)
+		fmt.Printf("     issuer:    %s\n", cd.Issuer)
+		fmt.Printf("     serial:    %s\n", cd.Serial)
+		fmt.Printf("     not after: %s\n", cd.NotAfter.Local().Format(time.RFC3339))
+		fmt.Printf("     key:       %s\n", cd.KeyType)
+		fmt.Printf("     sha256:    %s\n", cd.SHA256)
+	}
+
+	for _, issue := range d.ChainIssues {
+		fmt.Printf("warning: %s\n", issue)
+	}
+}
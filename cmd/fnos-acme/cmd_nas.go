@@ -0,0 +1,328 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim"
+	"github.com/cospotato/fnos-acme/internal/trim/api/remoteaccess"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgCert    = "cert"
+	flgIssuer  = "issuer"
+	flgDesc    = "desc"
+	flgDefault = "default"
+)
+
+func commandNAS() *cli.Command {
+	return &cli.Command{
+		Name:  "nas",
+		Usage: "manage the NAS directly",
+		Commands: []*cli.Command{
+			{
+				Name:  "certs",
+				Usage: "manage the certificates on the NAS",
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "list the certificates on the NAS",
+						Action: nasCertsList,
+						Flags:  []cli.Flag{outputFlag()},
+					},
+					{
+						Name:   "upload",
+						Usage:  "upload a certificate to the NAS",
+						Action: nasCertsUpload,
+						Flags: append(certFileFlags(),
+							&cli.BoolFlag{
+								Name:  flgDefault,
+								Usage: "use the certificate as the default one",
+							},
+						),
+					},
+					{
+						Name:      "replace",
+						Usage:     "replace a certificate on the NAS",
+						ArgsUsage: "<id>",
+						Action:    nasCertsReplace,
+						Flags:     certFileFlags(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func certFileFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     flgCert,
+			Usage:    "PEM file of the certificate, may contain the chain and the private key",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  flgKey,
+			Usage: "PEM file of the private key, default to the key in the certificate file",
+		},
+		&cli.StringFlag{
+			Name:  flgIssuer,
+			Usage: "PEM file of the issuer certificate",
+		},
+		&cli.StringFlag{
+			Name:  flgDesc,
+			Usage: "description of the certificate, set it to the group to let fnos-acme manage it",
+		},
+		outputFlag(),
+	}
+}
+
+// withNASClient runs fn with a client logged in to the NAS.
+func withNASClient(ctx context.Context, c *cli.Command, fn func(context.Context, *trim.Client) error) error {
+	nas := c.String(flgFnosAddress)
+	if nas == "" {
+		return fmt.Errorf("must specific FNOS_ADDRESS")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, err := newNASClient(c, nas)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return fn(ctx, client)
+}
+
+func nasCertsList(ctx context.Context, c *cli.Command) error {
+	return withNASClient(ctx, c, func(ctx context.Context, client *trim.Client) error {
+		certList, err := client.Main().RemoteAccessService().GetCertList(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printRemoteCerts(c, certList.Data)
+	})
+}
+
+func nasCertsUpload(ctx context.Context, c *cli.Command) error {
+	data, leafNotAfter, err := readCertRequestData(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flgDefault) {
+		data.IsDefault = 1
+	}
+
+	return withNASClient(ctx, c, func(ctx context.Context, client *trim.Client) error {
+		service := client.Main().RemoteAccessService()
+
+		before, err := service.GetCertList(ctx)
+		if err != nil {
+			return err
+		}
+
+		resp, err := service.UploadCert(ctx, &remoteaccess.UploadCertRequest{Data: data})
+		if err == nil && !resp.Data {
+			err = errors.New("upload cert return false")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		after, err := service.GetCertList(ctx)
+		if err != nil {
+			return err
+		}
+
+		// fnos does not return the id of the uploaded certificate, find the new one
+		for _, rc := range after.Data {
+			if !slices.ContainsFunc(before.Data, func(b remoteaccess.Cert) bool { return b.ID == rc.ID }) &&
+				!remoteCertValidTo(&rc).Before(leafNotAfter.Add(-time.Minute)) {
+				return printRemoteCerts(c, []remoteaccess.Cert{rc})
+			}
+		}
+
+		return errors.New("uploaded cert not found in fnos")
+	})
+}
+
+func nasCertsReplace(ctx context.Context, c *cli.Command) error {
+	id, err := certIDArg(c)
+	if err != nil {
+		return err
+	}
+
+	data, _, err := readCertRequestData(c)
+	if err != nil {
+		return err
+	}
+
+	data.ID = id
+
+	return withNASClient(ctx, c, func(ctx context.Context, client *trim.Client) error {
+		service := client.Main().RemoteAccessService()
+
+		if _, err := findRemoteCertByID(ctx, client, id); err != nil {
+			return err
+		}
+
+		resp, err := service.ReplaceCert(ctx, &remoteaccess.ReplaceCertRequest{Data: data})
+		if err == nil && !resp.Data {
+			err = errors.New("replace cert return false")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rc, err := findRemoteCertByID(ctx, client, id)
+		if err != nil {
+			return err
+		}
+
+		return printRemoteCerts(c, []remoteaccess.Cert{*rc})
+	})
+}
+
+func certIDArg(c *cli.Command) (int, error) {
+	if c.Args().Len() != 1 {
+		return 0, fmt.Errorf("must specific the cert id")
+	}
+
+	id, err := strconv.Atoi(c.Args().First())
+	if err != nil {
+		return 0, fmt.Errorf("invalid cert id %q", c.Args().First())
+	}
+
+	return id, nil
+}
+
+func findRemoteCertByID(ctx context.Context, client *trim.Client, id int) (*remoteaccess.Cert, error) {
+	certList, err := client.Main().RemoteAccessService().GetCertList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range certList.Data {
+		if certList.Data[i].ID == id {
+			return &certList.Data[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no cert %d in fnos", id)
+}
+
+// readCertRequestData reads the PEM files given by the flags, the key must match the certificate.
+// It returns the expiry of the certificate as well.
+func readCertRequestData(c *cli.Command) (remoteaccess.CertRequestData, time.Time, error) {
+	var data remoteaccess.CertRequestData
+
+	certPEM, err := os.ReadFile(c.String(flgCert))
+	if err != nil {
+		return data, time.Time{}, err
+	}
+
+	chain, err := certcrypto.ParsePEMBundle(certPEM)
+	if err != nil {
+		return data, time.Time{}, fmt.Errorf("%s: %w", c.String(flgCert), err)
+	}
+
+	keyPEM := findPEMPrivateKey(certPEM)
+	if keyFile := c.String(flgKey); keyFile != "" {
+		if keyPEM, err = os.ReadFile(keyFile); err != nil {
+			return data, time.Time{}, err
+		}
+	}
+
+	if keyPEM == nil {
+		return data, time.Time{}, fmt.Errorf("must specific the private key of %s", c.String(flgCert))
+	}
+
+	match, err := keyMatches(chain[0], keyPEM)
+	if err != nil {
+		return data, time.Time{}, err
+	}
+
+	if !match {
+		return data, time.Time{}, errors.New("private key does not match the certificate")
+	}
+
+	// only the certificates are uploaded, the private key is sent separately
+	var certs []byte
+	for _, cert := range chain {
+		certs = append(certs, certcrypto.PEMEncode(certcrypto.DERCertificateBytes(cert.Raw))...)
+	}
+
+	data.Desc = c.String(flgDesc)
+	data.CertificateBase64 = base64.StdEncoding.EncodeToString(certs)
+	data.PrivateKeyBase64 = base64.StdEncoding.EncodeToString(keyPEM)
+
+	if issuerFile := c.String(flgIssuer); issuerFile != "" {
+		issuerPEM, err := os.ReadFile(issuerFile)
+		if err != nil {
+			return data, time.Time{}, err
+		}
+
+		if _, err := certcrypto.ParsePEMBundle(issuerPEM); err != nil {
+			return data, time.Time{}, fmt.Errorf("%s: %w", issuerFile, err)
+		}
+
+		data.IssuerCertificateBase64 = base64.StdEncoding.EncodeToString(issuerPEM)
+	}
+
+	return data, chain[0].NotAfter, nil
+}
+
+func printRemoteCerts(c *cli.Command, certs []remoteaccess.Cert) error {
+	if c.String(flgOutput) == outputJSON {
+		if certs == nil {
+			certs = []remoteaccess.Cert{}
+		}
+
+		return printJSON(certs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDOMAIN\tSAN\tISSUED BY\tVALID TO\tSTATUS\tDEFAULT\tSOURCE\tDESC")
+
+	for _, rc := range certs {
+		validTo := "-"
+		if t := remoteCertValidTo(&rc); !t.IsZero() {
+			validTo = t.Local().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+			rc.ID,
+			rc.Domain,
+			rc.San,
+			rc.IssuedBy,
+			validTo,
+			rc.Status,
+			rc.IsDefault == 1,
+			rc.Source,
+			rc.Desc,
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cospotato/fnos-acme/internal/trim"
+	"github.com/cospotato/fnos-acme/internal/trim/rpc"
+	rpcerrors "github.com/cospotato/fnos-acme/internal/trim/rpc/errors"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgEncrypt  = "encrypt"
+	flgSkipSign = "skip-sign"
+	flgSession  = "session"
+)
+
+func commandRPC() *cli.Command {
+	return &cli.Command{
+		Name:  "rpc",
+		Usage: "call the fnos rpc directly",
+		Commands: []*cli.Command{
+			{
+				Name:      "call",
+				Usage:     "call a method with a json request, - to read the request from stdin",
+				ArgsUsage: "<method> [json]",
+				Action:    rpcCall,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  flgEncrypt,
+						Usage: "encrypt the request",
+					},
+					&cli.BoolFlag{
+						Name:  flgSkipSign,
+						Usage: "do not sign the request",
+					},
+					&cli.BoolFlag{
+						Name:  flgSession,
+						Usage: "add the session id of the login to the request",
+					},
+				},
+			},
+		},
+	}
+}
+
+func rpcCall(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() < 1 || c.Args().Len() > 2 {
+		return fmt.Errorf("must specific the method and optionally the json request")
+	}
+
+	method := c.Args().Get(0)
+
+	req := []byte("{}")
+	switch arg := c.Args().Get(1); arg {
+	case "":
+	case "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		req = data
+	default:
+		req = []byte(arg)
+	}
+
+	// the session id is merged into the request, so it must be an object
+	var fields map[string]any
+	if err := json.Unmarshal(req, &fields); err != nil {
+		return fmt.Errorf("request must be a json object: %w", err)
+	}
+
+	return withNASClient(ctx, c, func(ctx context.Context, client *trim.Client) error {
+		var opts []rpc.CallOption
+
+		if c.Bool(flgEncrypt) {
+			opts = append(opts, rpc.Encrypt())
+		}
+
+		if c.Bool(flgSkipSign) {
+			opts = append(opts, rpc.SkipSign())
+		}
+
+		if c.Bool(flgSession) {
+			opts = append(opts, rpc.Session(client.Session()))
+		}
+
+		var reply json.RawMessage
+
+		if err := client.Invoke(ctx, method, json.RawMessage(req), &reply, opts...); err != nil {
+			code := rpcerrors.Code(err)
+
+			fmt.Printf("errno: %d (%s)\n", uint32(code), code)
+
+			return err
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, reply, "", "  "); err != nil {
+			return err
+		}
+
+		fmt.Println("errno: 0")
+		fmt.Println(out.String())
+
+		return nil
+	})
+}
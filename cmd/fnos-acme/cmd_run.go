@@ -12,8 +12,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cospotato/fnos-acme/internal/notify"
 	"github.com/cospotato/fnos-acme/internal/trim"
 	"github.com/cospotato/fnos-acme/internal/trim/api/remoteaccess"
 	"github.com/go-acme/lego/v4/certcrypto"
@@ -21,6 +26,8 @@ import (
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
 	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -31,6 +38,30 @@ const (
 	flgCheckInterval        = "check-interval"
 	flgRenewDays            = "renew-days"
 	flgTermsOfServiceAgreed = "tos-agreed"
+	flgPreIssueHook         = "pre-issue-hook"
+	flgPostIssueHook        = "post-issue-hook"
+	flgPostDeployHook       = "post-deploy-hook"
+	flgHookTimeout          = "hook-timeout"
+	flgReplaceWindows       = "replace-windows"
+	flgRequireApproval      = "require-approval"
+	flgApprovalDeadline     = "approval-deadline"
+	flgImportDir            = "import-dir"
+	flgListen               = "listen"
+	flgCriticalDays         = "critical-days"
+	flgNotify               = "notify"
+	flgMQTTBroker           = "mqtt-broker"
+	flgMQTTUsername         = "mqtt-username"
+	flgMQTTPassword         = "mqtt-password"
+	flgMQTTClientID         = "mqtt-client-id"
+	flgMQTTTopicPrefix      = "mqtt-topic-prefix"
+	flgMQTTDiscoveryPrefix  = "mqtt-discovery-prefix"
+	flgTraceExporter        = "trace-exporter"
+	flgTraceEndpoint        = "trace-endpoint"
+	flgTraceSampleRatio     = "trace-sample-ratio"
+	flgAdminListen          = "admin-listen"
+	flgAdminToken           = "admin-token"
+	flgAdminTLSCert         = "admin-tls-cert"
+	flgAdminTLSKey          = "admin-tls-key"
 )
 
 const (
@@ -40,6 +71,7 @@ const (
 func commandRun() *cli.Command {
 	return &cli.Command{
 		Name:   "run",
+		Before: applyConfigFile,
 		Action: run,
 		Flags: []cli.Flag{
 			&cli.DurationFlag{
@@ -60,6 +92,151 @@ func commandRun() *cli.Command {
 				Usage:   "agree the acme term of service",
 				Sources: cli.EnvVars("ACME_TERM_OF_SERVICE_AGREED"),
 			},
+			&cli.StringFlag{
+				Name:    flgPreIssueHook,
+				Value:   "",
+				Usage:   "command to run before obtaining a certificate, failure aborts the issuance, it is not run by a shell",
+				Sources: cli.EnvVars("PRE_ISSUE_HOOK"),
+			},
+			&cli.StringFlag{
+				Name:    flgPostIssueHook,
+				Value:   "",
+				Usage:   "command to run after obtaining a certificate, it is not run by a shell",
+				Sources: cli.EnvVars("POST_ISSUE_HOOK"),
+			},
+			&cli.StringFlag{
+				Name:    flgPostDeployHook,
+				Value:   "",
+				Usage:   "command to run after deploying a certificate to fnos, it is not run by a shell",
+				Sources: cli.EnvVars("POST_DEPLOY_HOOK"),
+			},
+			&cli.DurationFlag{
+				Name:    flgHookTimeout,
+				Value:   2 * time.Minute,
+				Usage:   "hook command timeout",
+				Sources: cli.EnvVars("HOOK_TIMEOUT"),
+			},
+			&cli.StringSliceFlag{
+				Name:    flgReplaceWindows,
+				Value:   []string{},
+				Usage:   "local time windows allowed to replace the fnos certificate, e.g. 03:00-05:00",
+				Sources: cli.EnvVars("REPLACE_WINDOWS"),
+			},
+			&cli.BoolFlag{
+				Name:    flgRequireApproval,
+				Value:   false,
+				Usage:   "park newly issued certificates until approved by the approve command",
+				Sources: cli.EnvVars("REQUIRE_APPROVAL"),
+			},
+			&cli.DurationFlag{
+				Name:    flgApprovalDeadline,
+				Value:   72 * time.Hour,
+				Usage:   "approve pending certificates automatically after the deadline or a day before the current one expires, 0 to disable",
+				Sources: cli.EnvVars("APPROVAL_DEADLINE"),
+			},
+			&cli.StringFlag{
+				Name:    flgImportDir,
+				Value:   "",
+				Usage:   "directory watched for externally obtained certificates, as <name>.crt or <name>.pem with <name>.key, or a <name>.pem holding the key as well",
+				Sources: cli.EnvVars("IMPORT_DIR"),
+			},
+			&cli.StringFlag{
+				Name:    flgListen,
+				Value:   "",
+				Usage:   "address to serve metrics and health checks on, e.g. :9090",
+				Sources: cli.EnvVars("LISTEN"),
+			},
+			&cli.IntFlag{
+				Name:    flgCriticalDays,
+				Value:   1,
+				Usage:   "report not ready when a certificate expires within the days",
+				Sources: cli.EnvVars("CRITICAL_DAYS"),
+			},
+			&cli.StringSliceFlag{
+				Name:    flgNotify,
+				Value:   []string{},
+				Usage:   "notification urls, e.g. wecom://<key>?events=failed+expiring&expiring-days=7",
+				Sources: cli.EnvVars("NOTIFY"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTBroker,
+				Value:   "",
+				Usage:   "mqtt broker to publish certificate states, e.g. tcp://127.0.0.1:1883",
+				Sources: cli.EnvVars("MQTT_BROKER"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTUsername,
+				Value:   "",
+				Usage:   "mqtt username",
+				Sources: cli.EnvVars("MQTT_USERNAME"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTPassword,
+				Value:   "",
+				Usage:   "mqtt password",
+				Sources: cli.EnvVars("MQTT_PASSWORD"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTClientID,
+				Value:   "fnos-acme",
+				Usage:   "mqtt client id",
+				Sources: cli.EnvVars("MQTT_CLIENT_ID"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTTopicPrefix,
+				Value:   "fnos-acme",
+				Usage:   "mqtt topic prefix of certificate states and renew commands",
+				Sources: cli.EnvVars("MQTT_TOPIC_PREFIX"),
+			},
+			&cli.StringFlag{
+				Name:    flgMQTTDiscoveryPrefix,
+				Value:   "homeassistant",
+				Usage:   "home assistant mqtt discovery prefix",
+				Sources: cli.EnvVars("MQTT_DISCOVERY_PREFIX"),
+			},
+			&cli.StringFlag{
+				Name:    flgTraceExporter,
+				Value:   traceExporterNone,
+				Usage:   "trace exporter, one of none, otlp-grpc, otlp-http",
+				Sources: cli.EnvVars("TRACE_EXPORTER"),
+			},
+			&cli.StringFlag{
+				Name:    flgTraceEndpoint,
+				Usage:   "otlp endpoint url, e.g. http://localhost:4318, defaults to OTEL_EXPORTER_OTLP_ENDPOINT",
+				Sources: cli.EnvVars("TRACE_ENDPOINT"),
+			},
+			&cli.FloatFlag{
+				Name:    flgTraceSampleRatio,
+				Value:   1,
+				Usage:   "ratio of traces to sample",
+				Sources: cli.EnvVars("TRACE_SAMPLE_RATIO"),
+			},
+			&cli.DurationFlag{
+				Name:    flgShutdownTimeout,
+				Value:   8 * time.Second,
+				Usage:   "how long the in-flight step may take to finish on SIGTERM, docker stop kills after 10s by default",
+				Sources: cli.EnvVars("SHUTDOWN_TIMEOUT"),
+			},
+			&cli.StringFlag{
+				Name:    flgAdminListen,
+				Usage:   "address to serve the https admin api on, e.g. :8443, disabled if empty",
+				Sources: cli.EnvVars("ADMIN_LISTEN"),
+			},
+			&cli.StringFlag{
+				Name:    flgAdminToken,
+				Usage:   "bearer token of the admin api",
+				Sources: cli.EnvVars("ADMIN_TOKEN"),
+			},
+			&cli.StringFlag{
+				Name:    flgAdminTLSCert,
+				Usage:   "tls certificate of the admin api, defaults to the certificate of the main domain",
+				Sources: cli.EnvVars("ADMIN_TLS_CERT"),
+			},
+			&cli.StringFlag{
+				Name:    flgAdminTLSKey,
+				Usage:   "tls private key of the admin api",
+				Sources: cli.EnvVars("ADMIN_TLS_KEY"),
+			},
 		},
 	}
 }
@@ -89,6 +266,38 @@ func flagCheck(c *cli.Command) error {
 		return fmt.Errorf("must specific DNS_PROVIDER")
 	}
 
+	for _, rawURL := range c.StringSlice(flgNotify) {
+		if _, err := notify.Parse(rawURL); err != nil {
+			return fmt.Errorf("invalid notify url: %w", err)
+		}
+	}
+
+	if _, err := parseMaintenanceWindows(c.StringSlice(flgReplaceWindows)); err != nil {
+		return err
+	}
+
+	for _, name := range []string{flgPreIssueHook, flgPostIssueHook, flgPostDeployHook} {
+		if command := c.String(name); command != "" {
+			if _, err := splitCommand(command); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.String(flgAdminListen) != "" && c.String(flgAdminToken) == "" {
+		return fmt.Errorf("must specific ADMIN_TOKEN to serve admin api")
+	}
+
+	if (c.String(flgAdminTLSCert) == "") != (c.String(flgAdminTLSKey) == "") {
+		return fmt.Errorf("ADMIN_TLS_CERT and ADMIN_TLS_KEY must be specified together")
+	}
+
+	switch c.String(flgTraceExporter) {
+	case traceExporterNone, traceExporterOTLPGRPC, traceExporterOTLPHTTP:
+	default:
+		return fmt.Errorf("unknown trace exporter %q", c.String(flgTraceExporter))
+	}
+
 	return nil
 }
 
@@ -98,187 +307,943 @@ func run(ctx context.Context, c *cli.Command) error {
 		return err
 	}
 
-	if c.Bool(flgDebug) {
-		logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}))
-		slog.SetDefault(logger)
+	ctx = withLogAttrs(ctx, slog.String("nas", c.String(flgFnosAddress)))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stopping := watchShutdown(ctx, cancel, c.Duration(flgShutdownTimeout))
+
+	hangup, stopHangup := watchHangup()
+	defer stopHangup()
+
+	shutdownTracing, err := setupTracing(ctx, c)
+	if err != nil {
+		slog.Error("setup tracing failed", "err", err)
+		return err
 	}
 
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("shutdown tracing failed", "err", err)
+		}
+	}()
+
 	// create data dir
 	if _, err := os.Stat(c.String(flgDataDir)); errors.Is(err, os.ErrNotExist) {
 		if err := os.MkdirAll(c.String(flgDataDir), 0755); err != nil {
-			slog.Error("create data dir failed", "err", err)
+			slog.ErrorContext(ctx, "create data dir failed", "err", err)
 			return err
 		}
 	}
 
+	health := newHealthState(c.String(flgDataDir), c.Duration(flgCheckInterval), int(c.Int(flgCriticalDays)))
+
+	if addr := c.String(flgListen); addr != "" {
+		go func() {
+			if err := serveHTTP(ctx, addr, newOpsHandler(health)); err != nil {
+				slog.ErrorContext(ctx, "serve http failed", "addr", addr, "err", err)
+			}
+		}()
+	}
+
+	if c.Bool(flgDumpProtocol) {
+		slog.WarnContext(ctx, "fnos protocol dump enabled, debug logs contain passwords and private keys")
+	}
+
+	reconnected := make(chan struct{}, 1)
+	unreachable := make(chan error, 1)
+
+	connect := func(c *cli.Command) (*trim.Client, error) {
+		return newTrimClient(c, reconnected, unreachable)
+	}
+
 	// login fnos
-	client, err := trim.NewMainClient(c.String(flgFnosAddress), trim.WithLogin(
-		c.String(flgFnosUsername),
-		c.String(flgFnosPassword),
-	))
+	client, err := connect(c)
+	if err != nil {
+		slog.ErrorContext(ctx, "create fnos client failed", "err", err)
+		return err
+	}
+
+	// the client may be replaced by reload
+	defer func() { client.Close() }()
+
+	health.setTrimClient(client)
+
+	notifier, err := notify.NewDispatcher(c.StringSlice(flgNotify))
 	if err != nil {
-		slog.Error("create fnos client failed", "err", err)
 		return err
 	}
 
-	defer client.Close()
+	slog.InfoContext(ctx, "login fnos success")
 
-	slog.Info("login fnos success")
+	j := openJournal(c.String(flgDataDir))
+	observer := &challengeObserver{journal: j}
+	j.recordConfig(configSettings(c))
+
+	acme := func(c *cli.Command) (*lego.Client, error) {
+		return newACMEClient(ctx, c, j, observer)
+	}
 
 	// login acme
-	account, err := setupAccount(c.String(flgDataDir), c.String(flgEmail))
+	legoClient, err := acme(c)
 	if err != nil {
 		return err
 	}
 
-	legoClient, err := newClient(ctx, account, defaultKeyType, c.String(flgDnsProvider), 30*time.Second, c.StringSlice(flgDnsResolvers))
+	health.setRegistered()
+
+	publisher, err := newMQTTPublisher(c)
 	if err != nil {
+		slog.ErrorContext(ctx, "connect mqtt broker failed", "err", err)
 		return err
 	}
 
-	if account.Registration == nil {
-		// register account
-		reg, err := legoClient.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: c.Bool(flgTermsOfServiceAgreed)})
+	defer publisher.Close()
+
+	u, err := newUpdater(c, client, legoClient, notifier)
+	if err != nil {
+		return err
+	}
+
+	u.mqtt = publisher
+	u.journal = j
+	u.challenges = observer
+
+	var imports <-chan string
+	if dir := c.String(flgImportDir); dir != "" {
+		if imports, err = watchImportDir(ctx, dir); err != nil {
+			slog.ErrorContext(ctx, "watch import dir failed", "dir", dir, "err", err)
+			return err
+		}
+	}
+
+	// retry the deployments left by last run before checking
+	u.flushOutbox(ctx, true)
+
+	// do checkAndUpdate immediately at starting up
+	if err := u.checkAndUpdate(ctx); err != nil {
+		slog.ErrorContext(ctx, "check certificate and update failed", "err", err)
+		u.notifyFailed(ctx, "", err)
+	}
+
+	u.nextCheck = time.Now().Add(c.Duration(flgCheckInterval))
+	slog.InfoContext(ctx, "wait next sync", "nextSyncTime", u.nextCheck)
+
+	ticker := time.NewTicker(c.Duration(flgCheckInterval))
+
+	controls := make(chan controlRequest)
+
+	go func() {
+		if err := serveControl(ctx, controlSocketPath(u.dataDir), controls); err != nil {
+			slog.ErrorContext(ctx, "serve control socket failed", "err", err)
+		}
+	}()
+
+	defer os.Remove(controlSocketPath(u.dataDir))
+
+	// reload re-reads the config, then certificates are checked by the reload op
+	reload := func() (err error) {
+		// the config file is exported while it is parsed, the environment is restored if it is rejected
+		restore := saveConfigEnv()
+		defer func() {
+			if err != nil {
+				restore()
+			}
+		}()
+
+		next, err := reparseCommand(ctx)
 		if err != nil {
 			return err
 		}
 
-		account.Registration = reg
-		if err := saveAccount(c.String(flgDataDir), account); err != nil {
+		if err := u.reload(ctx, c, next, connect, acme); err != nil {
 			return err
 		}
 
-		slog.Info("registered acme account", "email", c.String(flgEmail))
-	}
+		c, client = next, u.trimClient
+		ctx = withLogAttrs(ctx, slog.String("nas", u.nas))
 
-	// do checkAndUpdate immediately at starting up
-	if err := checkAndUpdate(ctx, c, client, legoClient); err != nil {
-		slog.Error("check certificate and update failed", "err", err)
+		health.setTrimClient(client)
+		health.setInterval(c.Duration(flgCheckInterval))
+		ticker.Reset(c.Duration(flgCheckInterval))
+
+		return nil
 	}
 
-	slog.Info("wait next sync", "nextSyncTime", time.Now().Add(c.Duration(flgCheckInterval)))
+	if addr := c.String(flgAdminListen); addr != "" {
+		config, err := adminTLSConfig(c.String(flgAdminTLSCert), c.String(flgAdminTLSKey), u.dataDir, u.domains[0])
+		if err != nil {
+			slog.ErrorContext(ctx, "load admin api certificate failed", "err", err)
+			return err
+		}
 
-	ticker := time.NewTicker(c.Duration(flgCheckInterval))
+		go func() {
+			if err := serveHTTPS(ctx, addr, newAdminHandler(ctx, c.String(flgAdminToken), u.dataDir, controls), config); err != nil {
+				slog.ErrorContext(ctx, "serve admin api failed", "addr", addr, "err", err)
+			}
+		}()
+	}
+
+	// states are published after the steps which may change the certificates,
+	// building them queries the NAS so it is not done on every wake up
+	publish := true
 
 	for {
+		// stop before starting another step
+		select {
+		case <-stopping:
+			slog.InfoContext(ctx, "shutdown")
+			return nil
+		default:
+		}
+
+		health.tick()
+
+		if publish {
+			u.publishState(ctx)
+			publish = false
+		}
+
+		// wake up at the opening of maintenance window to apply deferred replacement
+		var windowOpen <-chan time.Time
+		if next := u.windows.nextStart(time.Now()); !next.IsZero() {
+			windowOpen = time.After(time.Until(next))
+		}
+
+		var outboxRetry <-chan time.Time
+		if next := u.outbox.nextAttempt(); !next.IsZero() {
+			outboxRetry = time.After(time.Until(next))
+		}
+
+		var digest <-chan time.Time
+		if next := u.notifier.NextDigest(); !next.IsZero() {
+			digest = time.After(time.Until(next))
+		}
+
 		select {
 		case <-ticker.C:
-			if err := checkAndUpdate(ctx, c, client, legoClient); err != nil {
-				slog.Error("check certificate and update failed", "err", err)
+			if err := u.checkAndUpdate(ctx); err != nil {
+				slog.ErrorContext(ctx, "check certificate and update failed", "err", err)
+				u.notifyFailed(ctx, "", err)
+			}
+
+			publish = true
+			u.nextCheck = time.Now().Add(c.Duration(flgCheckInterval))
+			slog.InfoContext(ctx, "wait next sync", "nextSyncTime", u.nextCheck)
+		case base := <-imports:
+			if err := u.importCertificate(ctx, base); err != nil {
+				slog.ErrorContext(ctx, "import certificate failed", "path", base, "err", err)
+				u.notifyFailed(ctx, filepath.Base(base), err)
+			}
+
+			publish = true
+		case <-reconnected:
+			slog.InfoContext(ctx, "fnos reconnected, retry pending deployments")
+			u.flushOutbox(ctx, true)
+			publish = true
+		case err := <-unreachable:
+			u.notify(ctx, notify.Event{
+				Type:  notify.EventNASUnreachable,
+				NAS:   u.nas,
+				Error: err.Error(),
+			})
+		case <-outboxRetry:
+			u.flushOutbox(ctx, false)
+			publish = true
+		case <-digest:
+			u.sendDigest(ctx)
+		case <-publisher.Refresh():
+			publish = true
+		case id := <-publisher.Renew():
+			if err := u.renewNow(ctx, id); err != nil {
+				slog.ErrorContext(ctx, "renew certificate failed", "id", id, "err", err)
+				u.notifyFailed(ctx, u.domains[0], err)
+			}
+
+			publish = true
+		case <-hangup:
+			slog.InfoContext(ctx, "SIGHUP received, reload config")
+
+			if err := reload(); err != nil {
+				slog.ErrorContext(ctx, "reload config failed", "err", err)
+				u.notifyFailed(ctx, "", err)
+
+				continue
 			}
 
-			slog.Info("wait next sync", "nextSyncTime", time.Now().Add(c.Duration(flgCheckInterval)))
+			u.control(ctx, controlRequest{Op: controlReload})
+
+			publish = true
+			u.nextCheck = time.Now().Add(c.Duration(flgCheckInterval))
+		case req := <-controls:
+			if req.Op == controlReload {
+				if err := reload(); err != nil {
+					slog.ErrorContext(ctx, "reload config failed", "err", err)
+					req.reply <- controlReply{Error: fmt.Sprintf("reload config: %s", err), err: err}
+
+					continue
+				}
+
+				u.nextCheck = time.Now().Add(c.Duration(flgCheckInterval))
+			}
+
+			req.reply <- u.control(ctx, req)
+			publish = req.Op != controlStatus
+		case <-windowOpen:
+			slog.InfoContext(ctx, "maintenance window opened")
+
+			if err := u.checkAndUpdate(ctx); err != nil {
+				slog.ErrorContext(ctx, "check certificate and update failed", "err", err)
+				u.notifyFailed(ctx, "", err)
+			}
+
+			publish = true
+		case <-stopping:
+			// returns at the top of the loop
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
 
-func ensureCert(ctx context.Context, trimClient *trim.Client, cert cert, edit bool) error {
-	certList, err := trimClient.Main().RemoteAccessService().GetCertList(context.TODO())
+func newTrimClient(c *cli.Command, reconnected chan<- struct{}, unreachable chan<- error) (*trim.Client, error) {
+	return trim.NewMainClient(c.String(flgFnosAddress), trim.WithLogin(
+		c.String(flgFnosUsername),
+		c.String(flgFnosPassword),
+	), trim.WithReconnectHandler(func(err error) {
+		metricReconnects.WithLabelValues(c.String(flgFnosAddress), outcomeOf(err)).Inc()
+
+		if err != nil {
+			select {
+			case unreachable <- err:
+			default:
+			}
+
+			return
+		}
+
+		select {
+		case reconnected <- struct{}{}:
+		default:
+		}
+	}), trim.WithUnaryInterceptor(metricsInterceptor, tracingInterceptor), trim.WithProtocolDump(c.Bool(flgDumpProtocol)))
+}
+
+// newACMEClient creates the lego client of the account, the account is registered if it has not been.
+func newACMEClient(ctx context.Context, c *cli.Command, j *journal, observer *challengeObserver) (*lego.Client, error) {
+	account, err := setupAccount(c.String(flgDataDir), c.String(flgEmail))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	legoClient, err := newClient(ctx, account, defaultKeyType, c.String(flgDnsProvider), 30*time.Second, c.StringSlice(flgDnsResolvers), observer)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Registration == nil {
+		// register account
+		reg, err := legoClient.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: c.Bool(flgTermsOfServiceAgreed)})
+		if err != nil {
+			return nil, err
+		}
+
+		account.Registration = reg
+		if err := saveAccount(c.String(flgDataDir), account); err != nil {
+			return nil, err
+		}
+
+		slog.InfoContext(ctx, "registered acme account", "email", c.String(flgEmail))
+		j.record(journalEntry{Type: journalAccountRegistered, Detail: map[string]string{"email": c.String(flgEmail), "uri": reg.URI}})
 	}
 
+	return legoClient, nil
+}
+
+func findRemoteCert(ctx context.Context, trimClient *trim.Client, name string) (*remoteaccess.Cert, error) {
+	certList, err := trimClient.Main().RemoteAccessService().GetCertList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pickRemoteCert(certList.Data, name), nil
+}
+
+// pickRemoteCert returns the copy of the group in fnos, the one uploaded by fnos-acme, i.e. described
+// as the group, is preferred if there are several of the domain.
+func pickRemoteCert(remoteCerts []remoteaccess.Cert, name string) *remoteaccess.Cert {
 	var remoteCert *remoteaccess.Cert
 
-	for i := range certList.Data {
-		if certList.Data[i].Domain == cert.name {
-			remoteCert = &certList.Data[i]
-			break
+	for i := range remoteCerts {
+		if remoteCerts[i].Domain != name {
+			continue
+		}
+
+		if remoteCert == nil || remoteCerts[i].Desc == name {
+			remoteCert = &remoteCerts[i]
 		}
 	}
 
+	return remoteCert
+}
+
+// remoteCertValidTo converts the expiry reported by fnos, which may be in seconds or milliseconds.
+func remoteCertValidTo(remoteCert *remoteaccess.Cert) time.Time {
+	switch {
+	case remoteCert.ValidTo <= 0:
+		return time.Time{}
+	case remoteCert.ValidTo > 1e12:
+		return time.UnixMilli(remoteCert.ValidTo)
+	default:
+		return time.Unix(remoteCert.ValidTo, 0)
+	}
+}
+
+// remoteCertStale reports whether the certificate in fnos is not the local one.
+func remoteCertStale(remoteCert *remoteaccess.Cert, cert cert) bool {
+	validTo := remoteCertValidTo(remoteCert)
+	if validTo.IsZero() || cert.Certificate == nil {
+		return false
+	}
+
+	diff := validTo.Sub(cert.NotAfter)
+
+	return diff > time.Minute || diff < -time.Minute
+}
+
+type updater struct {
+	nas              string
+	dataDir          string
+	domains          []string
+	renewDays        int
+	approval         bool
+	approvalDeadline time.Duration
+	hooks            *hooks
+	windows          maintenanceWindows
+	outbox           *outbox
+	notifier         *notify.Dispatcher
+	failures         []notify.Event
+	mqtt             *mqttPublisher
+	journal          *journal
+	challenges       *challengeObserver
+	legoClient       *lego.Client
+	trimClient       *trim.Client
+	configEnv        map[string]string
+	nextCheck        time.Time
+}
+
+func newUpdater(c *cli.Command, trimClient *trim.Client, legoClient *lego.Client, notifier *notify.Dispatcher) (*updater, error) {
+	windows, err := parseMaintenanceWindows(c.StringSlice(flgReplaceWindows))
+	if err != nil {
+		return nil, err
+	}
+
+	outbox, err := loadOutbox(c.String(flgDataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	return &updater{
+		nas:              c.String(flgFnosAddress),
+		dataDir:          c.String(flgDataDir),
+		domains:          c.StringSlice(flgDomains),
+		renewDays:        int(c.Int(flgRenewDays)),
+		approval:         c.Bool(flgRequireApproval),
+		approvalDeadline: c.Duration(flgApprovalDeadline),
+		hooks:            hooksFromCommand(c),
+		windows:          windows,
+		outbox:           outbox,
+		notifier:         notifier,
+		legoClient:       legoClient,
+		trimClient:       trimClient,
+		configEnv:        exportedConfigEnv(),
+	}, nil
+}
+
+// ensureCert uploads the certificate to fnos if not exists, or replaces the remote one if edit is set
+// or the remote one is stale. Replacement outside the maintenance windows is deferred unless the
+// remote one expires before the next window opens or its expiry is unknown.
+// It returns the certificate in fnos and whether the remote one has been changed.
+func (u *updater) ensureCert(ctx context.Context, cert cert, edit bool) (*remoteaccess.Cert, bool, error) {
+	remoteCert, err := findRemoteCert(ctx, u.trimClient, cert.name)
+	if err != nil {
+		return nil, false, err
+	}
+
 	if remoteCert == nil {
-		slog.Info("certificate in fnos not exists, upload it")
+		slog.InfoContext(ctx, "certificate in fnos not exists, upload it")
 
-		resp, err := trimClient.Main().RemoteAccessService().UploadCert(ctx, &remoteaccess.UploadCertRequest{
+		resp, err := u.trimClient.Main().RemoteAccessService().UploadCert(ctx, &remoteaccess.UploadCertRequest{
 			Data: remoteaccess.CertRequestData{
 				Desc:              cert.name,
 				PrivateKeyBase64:  base64.StdEncoding.EncodeToString(cert.rawKey),
 				CertificateBase64: base64.StdEncoding.EncodeToString(cert.rawCert),
 			},
 		})
+		if err == nil && !resp.Data {
+			err = errors.New("upload cert return false")
+		}
+
 		if err != nil {
-			return err
+			u.journalNAS(journalNASUpload, cert, 0, err)
+			return nil, false, err
 		}
 
-		if !resp.Data {
-			return errors.New("upload cert return false")
+		remoteCert, err = findRemoteCert(ctx, u.trimClient, cert.name)
+		if err == nil && remoteCert == nil {
+			err = errors.New("uploaded cert not found in fnos")
 		}
+
+		if err != nil {
+			u.journalNAS(journalNASUpload, cert, 0, err)
+			return nil, true, err
+		}
+
+		u.journalNAS(journalNASUpload, cert, remoteCert.ID, nil)
+
+		return remoteCert, true, nil
 	}
 
-	if edit {
-		slog.Info("certificate in fnos out of date, replace it")
+	if !edit && !remoteCertStale(remoteCert, cert) {
+		return remoteCert, false, nil
+	}
 
-		resp, err := trimClient.Main().RemoteAccessService().ReplaceCert(ctx, &remoteaccess.ReplaceCertRequest{
-			Data: remoteaccess.CertRequestData{
-				ID:                remoteCert.ID,
-				Desc:              cert.name,
-				PrivateKeyBase64:  base64.StdEncoding.EncodeToString(cert.rawKey),
-				CertificateBase64: base64.StdEncoding.EncodeToString(cert.rawCert),
-			},
+	now := time.Now()
+	validTo := remoteCertValidTo(remoteCert)
+
+	if open, ok := u.windows.deferReplace(now, validTo); ok {
+		slog.InfoContext(ctx, "certificate replacement deferred to maintenance window", "windowOpen", open)
+		u.journal.record(journalEntry{
+			Type:      journalNASReplaceDeferred,
+			Group:     cert.name,
+			NAS:       u.nas,
+			NASCertID: remoteCert.ID,
+			Detail:    map[string]string{"windowOpen": open.Format(time.RFC3339)},
 		})
-		if err != nil {
-			return err
+		return remoteCert, false, nil
+	}
+
+	switch {
+	case u.windows.contains(now):
+	case validTo.IsZero():
+		slog.WarnContext(ctx, "expiry of certificate in fnos unknown, replace it outside maintenance window")
+	default:
+		slog.WarnContext(ctx, "certificate in fnos expires before maintenance window", "validTo", validTo)
+	}
+
+	slog.InfoContext(ctx, "certificate in fnos out of date, replace it")
+
+	resp, err := u.trimClient.Main().RemoteAccessService().ReplaceCert(ctx, &remoteaccess.ReplaceCertRequest{
+		Data: remoteaccess.CertRequestData{
+			ID:                remoteCert.ID,
+			Desc:              cert.name,
+			PrivateKeyBase64:  base64.StdEncoding.EncodeToString(cert.rawKey),
+			CertificateBase64: base64.StdEncoding.EncodeToString(cert.rawCert),
+		},
+	})
+	if err == nil && !resp.Data {
+		err = errors.New("replace cert return false")
+	}
+
+	u.journalNAS(journalNASReplace, cert, remoteCert.ID, err)
+
+	if err != nil {
+		return remoteCert, false, err
+	}
+
+	return remoteCert, true, nil
+}
+
+// journalNAS records the upload or replacement of the certificate in fnos.
+func (u *updater) journalNAS(typ string, cert cert, id int, err error) {
+	e := journalEntry{
+		Type:      typ,
+		Group:     cert.name,
+		NAS:       u.nas,
+		NASCertID: id,
+		Detail: map[string]string{
+			"serial":   cert.SerialNumber.Text(16),
+			"notAfter": cert.NotAfter.Format(time.RFC3339),
+		},
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	u.journal.record(e)
+}
+
+// deployCert runs ensureCert and launches the post deploy hook when the remote certificate has been touched.
+// Failed deployment is queued in the outbox and retried later, so is a deferred replacement until the
+// maintenance window opens. A queued replacement is carried over even if edit is not set.
+func (u *updater) deployCert(ctx context.Context, env hookEnv, cert cert, edit bool) (err error) {
+	ctx = withLogAttrs(ctx, slog.String("group", cert.name))
+
+	if op, ok := u.outbox.get(cert.name); ok {
+		edit = edit || op.Edit
+	}
+
+	ctx, span := tracer.Start(ctx, "deploy", trace.WithAttributes(
+		attribute.String("group", cert.name),
+		attribute.String("nas", u.nas),
+	))
+	defer func() { endSpan(span, err) }()
+
+	remoteCert, changed, err := u.ensureCert(ctx, cert, edit)
+
+	id := 0
+	if remoteCert != nil {
+		id = remoteCert.ID
+		span.SetAttributes(attribute.Int("nas.cert_id", id), attribute.Bool("nas.changed", changed))
+
+		validTo := remoteCertValidTo(remoteCert)
+		if changed {
+			validTo = cert.NotAfter
 		}
 
-		if !resp.Data {
-			return errors.New("upload cert return false")
+		metricNASCertNotAfter.WithLabelValues(cert.name, u.nas).Set(float64(validTo.Unix()))
+	}
+
+	if changed && err == nil {
+		metricLastDeploy.WithLabelValues(cert.name, u.nas).SetToCurrentTime()
+
+		u.notify(ctx, notify.Event{
+			Type:     notify.EventDeployed,
+			Group:    cert.name,
+			Domains:  cert.DNSNames,
+			NAS:      u.nas,
+			NASCert:  id,
+			NotAfter: cert.NotAfter,
+		})
+	}
+
+	if changed || err != nil {
+		u.hooks.notify(ctx, hookPostDeploy, env.withResult(id, err))
+	}
+
+	if err != nil {
+		if err := u.outbox.add(cert.name, edit, err); err != nil {
+			slog.ErrorContext(ctx, "queue pending deployment failed", "err", err)
 		}
+
+		return err
+	}
+
+	// edit without change means the replacement is deferred to the maintenance window
+	if edit && !changed {
+		open := time.Now()
+		if !u.windows.contains(open) {
+			open = u.windows.nextStart(open)
+		}
+
+		if err := u.outbox.postpone(cert.name, edit, open); err != nil {
+			slog.ErrorContext(ctx, "queue deferred deployment failed", "err", err)
+		}
+
+		return nil
+	}
+
+	if err := u.outbox.remove(cert.name); err != nil {
+		slog.ErrorContext(ctx, "remove pending deployment failed", "err", err)
 	}
 
 	return nil
 }
 
-func obtainAndUpload(ctx context.Context, dataDir string, domains []string, legoClient *lego.Client, trimClient *trim.Client) error {
+// flushOutbox retries the pending deployments which are due, or all of them if force is set.
+func (u *updater) flushOutbox(ctx context.Context, force bool) {
+	ops := u.outbox.due(time.Now(), force)
+	if len(ops) == 0 {
+		return
+	}
+
+	ctx, span := tracer.Start(ctx, "flush outbox", trace.WithAttributes(attribute.Int("outbox.due", len(ops))))
+	defer span.End()
+
+	certs, err := listManagedCertificates(u.dataDir)
+	if err != nil {
+		slog.ErrorContext(ctx, "list certificates failed", "err", err)
+		return
+	}
+
+	for _, op := range ops {
+		ctx := withLogAttrs(ctx, slog.String("group", op.Group))
+
+		idx := slices.IndexFunc(certs, func(c cert) bool { return c.name == op.Group })
+		if idx < 0 {
+			slog.WarnContext(ctx, "drop pending deployment of missing certificate")
+
+			if err := u.outbox.remove(op.Group); err != nil {
+				slog.ErrorContext(ctx, "remove pending deployment failed", "err", err)
+			}
+
+			continue
+		}
+
+		slog.InfoContext(ctx, "retry pending deployment", "attempts", op.Attempts)
+
+		if err := u.deployCert(ctx, certHookEnv(u.dataDir, certs[idx]), certs[idx], op.Edit); err != nil {
+			slog.ErrorContext(ctx, "retry pending deployment failed", "err", err)
+			u.notifyFailed(ctx, op.Group, err)
+		}
+	}
+}
+
+// notify sends the event in background, so that slow notifiers never block the run loop.
+func (u *updater) notify(ctx context.Context, e notify.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	go u.notifier.Notify(context.WithoutCancel(ctx), e)
+}
+
+func (u *updater) notifyFailed(ctx context.Context, group string, err error) {
+	e := notify.Event{
+		Type:  notify.EventFailed,
+		Group: group,
+		NAS:   u.nas,
+		Error: err.Error(),
+		Time:  time.Now(),
+	}
+
+	u.recordFailure(e)
+	u.notify(ctx, e)
+}
+
+func (u *updater) obtainAndUpload(ctx context.Context) (err error) {
+	env := hookEnv{
+		group:   u.domains[0],
+		domains: u.domains,
+		dataDir: u.dataDir,
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("group", env.group))
+
+	ctx, span := tracer.Start(ctx, "issue", trace.WithAttributes(
+		attribute.String("group", env.group),
+		attribute.StringSlice("domains", u.domains),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := u.hooks.run(ctx, hookPreIssue, env); err != nil {
+		return fmt.Errorf("pre-issue hook failed: %w", err)
+	}
+
 	request := certificate.ObtainRequest{
-		Domains: domains,
+		Domains: u.domains,
 		Bundle:  true,
 	}
 
-	certResource, err := legoClient.Certificate.Obtain(request)
+	// lego does not take context, the dns provider picks the trace context from the observer
+	obtainCtx, obtainSpan := tracer.Start(ctx, "acme.obtain")
+	reset := u.challenges.begin(obtainCtx)
+	certResource, err := u.legoClient.Certificate.Obtain(request)
+	reset()
+	endSpan(obtainSpan, err)
+
+	metricACMEOrders.WithLabelValues(env.group, outcomeOf(err)).Inc()
+
+	order := journalEntry{
+		Type:   journalOrder,
+		Group:  env.group,
+		Detail: map[string]string{"domains": strings.Join(u.domains, ","), "outcome": outcomeOf(err)},
+	}
+
 	if err != nil {
+		order.Error = err.Error()
+		u.journal.record(order)
+		u.hooks.notify(ctx, hookPostIssue, env.withResult(0, err))
 		return err
 	}
 
-	if err := saveCertificate(dataDir, certResource); err != nil {
+	u.journal.record(order)
+
+	env.group = certResource.Domain
+
+	metricLastIssue.WithLabelValues(env.group).SetToCurrentTime()
+
+	pCert, err := certcrypto.ParsePEMCertificate(certResource.Certificate)
+	if err != nil {
+		return err
+	}
+
+	u.journal.record(journalEntry{
+		Type:  journalCertIssued,
+		Group: env.group,
+		Detail: map[string]string{
+			"certUrl":  certResource.CertURL,
+			"serial":   pCert.SerialNumber.Text(16),
+			"notAfter": pCert.NotAfter.Format(time.RFC3339),
+			"pending":  strconv.FormatBool(u.approval),
+		},
+	})
+
+	if u.approval {
+		if err := savePendingCertificate(u.dataDir, certResource); err != nil {
+			u.hooks.notify(ctx, hookPostIssue, env.withResult(0, err))
+			return err
+		}
+
+		u.hooks.notify(ctx, hookPostIssue, env)
+		u.notify(ctx, notify.Event{Type: notify.EventIssued, Group: env.group, Domains: u.domains})
+
+		if u.approvalDeadline > 0 {
+			cutoff, err := u.approvalCutoff(env.group, time.Now())
+			if err != nil {
+				return err
+			}
+
+			slog.InfoContext(ctx, "certificate is waiting for approval", "deadline", cutoff)
+		} else {
+			slog.InfoContext(ctx, "certificate is waiting for approval")
+		}
+
+		return nil
+	}
+
+	if err := saveCertificate(u.dataDir, certResource); err != nil {
+		u.hooks.notify(ctx, hookPostIssue, env.withResult(0, err))
 		return err
 	}
 
-	return ensureCert(ctx, trimClient, cert{
-		name:    certResource.Domain,
-		rawCert: certResource.Certificate,
-		rawKey:  certResource.PrivateKey,
+	u.hooks.notify(ctx, hookPostIssue, env)
+
+	metricCertNotAfter.WithLabelValues(certResource.Domain).Set(float64(pCert.NotAfter.Unix()))
+
+	u.notify(ctx, notify.Event{Type: notify.EventIssued, Group: env.group, Domains: u.domains, NotAfter: pCert.NotAfter})
+
+	return u.deployCert(ctx, env, cert{
+		Certificate: pCert,
+		name:        certResource.Domain,
+		rawCert:     certResource.Certificate,
+		rawKey:      certResource.PrivateKey,
 	}, true)
 }
 
-func checkAndUpdate(ctx context.Context, c *cli.Command, trimClient *trim.Client, legoClient *lego.Client) error {
-	slog.Info("start check certificate")
+// approvalMargin is how long before the current certificate expires the pending one is approved at the latest.
+const approvalMargin = 24 * time.Hour
+
+// approvalCutoff returns when the pending certificate of the group is approved automatically, after the deadline
+// but no later than approvalMargin before the current certificate expires, so the NAS never serves an expired one.
+func (u *updater) approvalCutoff(group string, issuedAt time.Time) (time.Time, error) {
+	cutoff := issuedAt.Add(u.approvalDeadline)
 
-	certs, err := listCertificates(c.String(flgDataDir))
+	certs, err := listCertificates(u.dataDir)
+	if err != nil {
+		return cutoff, err
+	}
+
+	if idx := slices.IndexFunc(certs, func(c cert) bool { return c.name == group }); idx >= 0 {
+		if expiry := certs[idx].NotAfter.Add(-approvalMargin); expiry.Before(cutoff) {
+			cutoff = expiry
+		}
+	}
+
+	return cutoff, nil
+}
+
+// approveExpired approves the pending certificates which are past the cut-off and deploys them.
+func (u *updater) approveExpired(ctx context.Context) error {
+	if u.approvalDeadline <= 0 {
+		return nil
+	}
+
+	pendings, err := listPendingCertificates(u.dataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pendings {
+		ctx := withLogAttrs(ctx, slog.String("group", p.name))
+
+		cutoff, err := u.approvalCutoff(p.name, p.issuedAt)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().Before(cutoff) {
+			continue
+		}
+
+		slog.WarnContext(ctx, "approval deadline exceeded, approve certificate automatically", "issuedAt", p.issuedAt)
+
+		if err := approveCertificate(u.dataDir, p.name); err != nil {
+			return err
+		}
+
+		u.journal.record(journalEntry{Type: journalCertApproved, Group: p.name, Detail: map[string]string{"by": "deadline"}})
+
+		// the approved certificate is pushed even if fnos does not report the expiry of its copy,
+		// a failed deployment is queued in the outbox
+		if _, err := u.deploy(ctx, p.name, ""); err != nil {
+			slog.ErrorContext(ctx, "deploy approved certificate failed", "err", err)
+		}
+	}
+
+	return nil
+}
+
+// obtainIfNotPending obtains a new certificate unless one is already waiting for approval,
+// it reports whether a new certificate has been obtained.
+func (u *updater) obtainIfNotPending(ctx context.Context) (bool, error) {
+	ctx = withLogAttrs(ctx, slog.String("group", u.domains[0]))
+
+	pending, err := hasPendingCertificate(u.dataDir, u.domains[0])
+	if err != nil {
+		return false, err
+	}
+
+	if pending {
+		slog.InfoContext(ctx, "certificate is waiting for approval")
+		return false, nil
+	}
+
+	return true, u.obtainAndUpload(ctx)
+}
+
+func (u *updater) checkAndUpdate(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "check", trace.WithAttributes(attribute.String("group", u.domains[0])))
+	defer func() { endSpan(span, err) }()
+
+	slog.InfoContext(ctx, "start check certificate")
+
+	if err := u.approveExpired(ctx); err != nil {
+		return err
+	}
+
+	certs, err := listCertificates(u.dataDir)
 	if err != nil {
 		return err
 	}
 
 	if len(certs) == 0 {
-		slog.Info("no certificate found, obtain one")
-		return obtainAndUpload(ctx, c.String(flgDataDir), c.StringSlice(flgDomains), legoClient, trimClient)
+		slog.InfoContext(ctx, "no certificate found, obtain one")
+		_, err := u.obtainIfNotPending(ctx)
+		return err
 	}
 
 	for _, cert := range certs {
+		ctx := withLogAttrs(ctx, slog.String("group", cert.name))
+
+		metricCertNotAfter.WithLabelValues(cert.name).Set(float64(cert.NotAfter.Unix()))
+		u.notifyExpiring(ctx, cert)
+
 		ok := func() bool {
-			if cert.name != c.StringSlice(flgDomains)[0] {
+			if cert.name != u.domains[0] {
 				return false
 			}
 
-			if !domainsEqual(c.StringSlice(flgDomains), certcrypto.ExtractDomains(cert.Certificate)) {
+			if !domainsEqual(u.domains, certcrypto.ExtractDomains(cert.Certificate)) {
 				return false
 			}
 
-			if time.Now().AddDate(0, 0, int(c.Int(flgRenewDays))).After(cert.NotAfter) {
+			if time.Now().AddDate(0, 0, u.renewDays).After(cert.NotAfter) {
 				return false
 			}
 
@@ -286,18 +1251,57 @@ func checkAndUpdate(ctx context.Context, c *cli.Command, trimClient *trim.Client
 		}
 
 		if !ok() {
-			slog.Info("certificate found, but out of date, obtain one and upload", "domain", cert.name)
-			if err := obtainAndUpload(ctx, c.String(flgDataDir), c.StringSlice(flgDomains), legoClient, trimClient); err != nil {
+			slog.InfoContext(ctx, "certificate found, but out of date, obtain one and upload")
+			obtained, err := u.obtainIfNotPending(ctx)
+			if err != nil {
 				return err
 			}
+
+			// the new certificate has been deployed or queued by obtainAndUpload
+			if obtained {
+				continue
+			}
+		}
+
+		if err := u.deployCert(ctx, certHookEnv(u.dataDir, cert), cert, false); err != nil {
+			return err
 		}
+	}
+
+	imported, err := listImportedCertificates(u.dataDir)
+	if err != nil {
+		return err
+	}
+
+	// imported certificates can not be renewed, only monitored and deployed
+	for _, cert := range imported {
+		ctx := withLogAttrs(ctx, slog.String("group", cert.name))
 
-		if err := ensureCert(ctx, trimClient, cert, false); err != nil {
+		metricCertNotAfter.WithLabelValues(cert.name).Set(float64(cert.NotAfter.Unix()))
+		u.notifyExpiring(ctx, cert)
+
+		if time.Now().AddDate(0, 0, u.renewDays).After(cert.NotAfter) {
+			slog.WarnContext(ctx, "imported certificate is expiring, replace it in the import dir", "notAfter", cert.NotAfter)
+		}
+
+		if err := u.deployCert(ctx, certHookEnv(u.dataDir, cert), cert, false); err != nil {
 			return err
 		}
 	}
 
-	slog.Info("certificate is ready")
+	metricLastCheck.SetToCurrentTime()
+
+	slog.InfoContext(ctx, "certificate is ready")
 
 	return nil
 }
+
+// notifyExpiring reports the certificate expiry on every check, the targets filter it by their expiring days.
+func (u *updater) notifyExpiring(ctx context.Context, cert cert) {
+	u.notify(ctx, notify.Event{
+		Type:     notify.EventExpiring,
+		Group:    cert.name,
+		Domains:  cert.DNSNames,
+		NotAfter: cert.NotAfter,
+	})
+}
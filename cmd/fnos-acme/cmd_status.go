@@ -0,0 +1,239 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim"
+	"github.com/cospotato/fnos-acme/internal/trim/api/remoteaccess"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgOutput = "output"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+const (
+	driftInSync    = "in sync"
+	driftStale     = "stale on NAS"
+	driftMissing   = "missing on NAS"
+	driftUnmanaged = "unmanaged conflict"
+	driftUnknown   = "unknown"
+)
+
+func commandStatus() *cli.Command {
+	return &cli.Command{
+		Name:   "status",
+		Usage:  "compare the certificates in the data dir with the copies on the NAS",
+		Action: status,
+		Flags: []cli.Flag{
+			outputFlag(),
+		},
+	}
+}
+
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  flgOutput,
+		Value: outputTable,
+		Usage: "output format, table or json",
+		Validator: func(output string) error {
+			if output != outputTable && output != outputJSON {
+				return fmt.Errorf("unknown output %q, must be %s or %s", output, outputTable, outputJSON)
+			}
+
+			return nil
+		},
+	}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// groupDrift is the state of a managed group on a NAS.
+type groupDrift struct {
+	Group      string    `json:"group"`
+	Source     string    `json:"source"`
+	Serial     string    `json:"serial"`
+	NotAfter   time.Time `json:"notAfter"`
+	NAS        string    `json:"nas"`
+	NASCertID  int       `json:"nasCertId,omitempty"`
+	NASValidTo time.Time `json:"nasValidTo"`
+	SANMatch   bool      `json:"sanMatch"`
+	Default    bool      `json:"default"`
+	Drift      string    `json:"drift"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func status(ctx context.Context, c *cli.Command) error {
+	nas := c.String(flgFnosAddress)
+	if nas == "" {
+		return fmt.Errorf("must specific FNOS_ADDRESS")
+	}
+
+	acmeCerts, err := listCertificates(c.String(flgDataDir))
+	if err != nil {
+		return err
+	}
+
+	imported, err := listImportedCertificates(c.String(flgDataDir))
+	if err != nil {
+		return err
+	}
+
+	// the error of the NAS is reported in every row instead of failing the command
+	remoteCerts, listErr := listRemoteCerts(ctx, c, nas)
+
+	var drifts []groupDrift
+
+	add := func(source string, c cert) {
+		d := groupDrift{
+			Group:    c.name,
+			Source:   source,
+			Serial:   c.SerialNumber.Text(16),
+			NotAfter: c.NotAfter,
+			NAS:      nas,
+		}
+
+		if listErr != nil {
+			d.Drift, d.Error = driftUnknown, listErr.Error()
+		} else {
+			compareRemoteCert(&d, c, remoteCerts)
+		}
+
+		drifts = append(drifts, d)
+	}
+
+	for _, c := range acmeCerts {
+		add("acme", c)
+	}
+
+	for _, c := range imported {
+		add("imported", c)
+	}
+
+	if c.String(flgOutput) == outputJSON {
+		return printJSON(drifts)
+	}
+
+	fmt.Printf("nas: %s\n", nas)
+
+	if listErr != nil {
+		fmt.Printf("error: %s\n", listErr)
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSOURCE\tSERIAL\tEXPIRES\tNAS CERT\tNAS VALID TO\tSAN\tDEFAULT\tDRIFT")
+
+	for _, d := range drifts {
+		nasCert, validTo, san, isDefault := "-", "-", "-", "-"
+		if d.NASCertID != 0 {
+			nasCert = strconv.Itoa(d.NASCertID)
+			san = "differ"
+			if d.SANMatch {
+				san = "match"
+			}
+
+			isDefault = strconv.FormatBool(d.Default)
+		}
+
+		if !d.NASValidTo.IsZero() {
+			validTo = d.NASValidTo.Local().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.Group,
+			d.Source,
+			d.Serial,
+			d.NotAfter.Local().Format(time.RFC3339),
+			nasCert,
+			validTo,
+			san,
+			isDefault,
+			d.Drift,
+		)
+	}
+
+	return w.Flush()
+}
+
+// listRemoteCerts logs in to the NAS and lists its certificates.
+func listRemoteCerts(ctx context.Context, c *cli.Command, nas string) ([]remoteaccess.Cert, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, err := newNASClient(c, nas)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	certList, err := client.Main().RemoteAccessService().GetCertList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return certList.Data, nil
+}
+
+// newNASClient logs in to the NAS with the fnos flags.
+func newNASClient(c *cli.Command, nas string) (*trim.Client, error) {
+	return trim.NewMainClient(nas,
+		trim.WithLogin(c.String(flgFnosUsername), c.String(flgFnosPassword)),
+		trim.WithProtocolDump(c.Bool(flgDumpProtocol)),
+	)
+}
+
+// compareRemoteCert fills the NAS copy of the group and the drift verdict. A copy of the domain
+// which was not uploaded by fnos-acme, i.e. described otherwise, is an unmanaged conflict.
+func compareRemoteCert(d *groupDrift, c cert, remoteCerts []remoteaccess.Cert) {
+	remoteCert := pickRemoteCert(remoteCerts, c.name)
+	if remoteCert == nil {
+		d.Drift = driftMissing
+		return
+	}
+
+	d.NASCertID = remoteCert.ID
+	d.NASValidTo = remoteCertValidTo(remoteCert)
+	d.SANMatch = domainsEqual(c.DNSNames, splitSAN(remoteCert.San))
+	d.Default = remoteCert.IsDefault == 1
+
+	switch {
+	case remoteCert.Desc != c.name:
+		d.Drift = driftUnmanaged
+	case remoteCertStale(remoteCert, c) || !d.SANMatch:
+		d.Drift = driftStale
+	default:
+		d.Drift = driftInSync
+	}
+}
+
+// splitSAN splits the SAN reported by fnos.
+func splitSAN(san string) []string {
+	return strings.FieldsFunc(san, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+}
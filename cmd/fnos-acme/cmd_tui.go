@@ -0,0 +1,635 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+const (
+	flgRefresh = "refresh"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiInvert = "\x1b[7m"
+)
+
+// tuiLogTail is how many bytes of the log file are read for the log tail.
+const tuiLogTail = 32 << 10
+
+func commandTUI() *cli.Command {
+	return &cli.Command{
+		Name:   "tui",
+		Usage:  "interactive view of the certificates, the NAS and the log, attached to the running daemon if any",
+		Action: runTUI,
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  flgRefresh,
+				Value: 10 * time.Second,
+				Usage: "how often the status is refreshed",
+			},
+		},
+	}
+}
+
+// tui shows the status of the daemon through the control socket,
+// it falls back to a read-only view of the data dir if the daemon is not running.
+type tui struct {
+	dataDir string
+	nas     string
+	logFile string
+	client  *controlClient
+
+	status   *daemonStatus
+	attached bool
+	loadErr  error
+	loading  bool
+	loadedAt time.Time
+	logLines []string
+
+	selected int
+	message  string
+	failed   bool
+	confirm  *controlRequest
+	busy     bool
+
+	// updates are applied by the ui loop, they are sent by the loaders and the actions
+	updates chan func()
+}
+
+func runTUI(ctx context.Context, c *cli.Command) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("tui needs a terminal")
+	}
+
+	t := &tui{
+		dataDir: c.String(flgDataDir),
+		nas:     c.String(flgFnosAddress),
+		logFile: logFilePath(c),
+		client:  newControlClient(c.String(flgDataDir)),
+		updates: make(chan func(), 8),
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+
+	// logs of this process would break the screen
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// alternate screen, hidden cursor and no line wrapping
+	fmt.Print("\x1b[?1049h\x1b[?25l\x1b[?7l")
+
+	defer func() {
+		fmt.Print("\x1b[?7h\x1b[?25h\x1b[?1049l")
+		_ = term.Restore(in, state)
+		slog.SetDefault(logger)
+	}()
+
+	return t.loop(ctx, c.Duration(flgRefresh))
+}
+
+func (t *tui) loop(ctx context.Context, refresh time.Duration) error {
+	keys := make(chan string)
+
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+
+			keys <- string(buf[:n])
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	t.load(ctx)
+
+	for {
+		t.draw()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case key, ok := <-keys:
+			if !ok || !t.key(ctx, key) {
+				return nil
+			}
+		case update := <-t.updates:
+			update()
+		case <-ticker.C:
+			if !t.loading && time.Since(t.loadedAt) >= refresh {
+				t.load(ctx)
+			}
+		}
+	}
+}
+
+// key handles the key press, it returns false to quit.
+func (t *tui) key(ctx context.Context, key string) bool {
+	if t.confirm != nil {
+		req := *t.confirm
+		t.confirm = nil
+
+		if key == "y" || key == "Y" {
+			t.do(ctx, req)
+		} else {
+			t.message = ""
+		}
+
+		return true
+	}
+
+	switch key {
+	case "q", "\x03", "\x1b":
+		return false
+	case "k", "\x1b[A", "\x1bOA":
+		t.selected = max(t.selected-1, 0)
+	case "j", "\x1b[B", "\x1bOB":
+		t.selected++
+	case "u":
+		if !t.loading {
+			t.load(ctx)
+		}
+	case "c":
+		t.do(ctx, controlRequest{Op: controlCheck})
+	case "r":
+		t.ask(controlRenew, "renew")
+	case "d":
+		t.ask(controlDeploy, "deploy")
+	case "b":
+		t.ask(controlRollback, "roll back")
+	}
+
+	return true
+}
+
+func (t *tui) selectedGroup() string {
+	if t.status == nil || len(t.status.Certificates) == 0 {
+		return ""
+	}
+
+	t.selected = min(t.selected, len(t.status.Certificates)-1)
+
+	return t.status.Certificates[t.selected].Group
+}
+
+// ask asks for confirmation before the action on the selected group.
+func (t *tui) ask(op, verb string) {
+	group := t.selectedGroup()
+	if group == "" {
+		return
+	}
+
+	t.confirm = &controlRequest{Op: op, Group: group}
+	t.message = fmt.Sprintf("%s %s? [y/N]", verb, group)
+	t.failed = false
+}
+
+// do sends the request to the daemon, the reply is shown when it is done.
+func (t *tui) do(ctx context.Context, req controlRequest) {
+	switch {
+	case !t.attached:
+		t.message, t.failed = "read-only, daemon is not running", true
+		return
+	case t.busy:
+		t.message, t.failed = "another action is running", true
+		return
+	}
+
+	t.busy = true
+	t.message, t.failed = strings.TrimSpace(req.Op+" "+req.Group)+" ...", false
+
+	go func() {
+		reply, err := t.client.do(ctx, http.MethodPost, "/v1/"+req.Op, &req)
+
+		t.updates <- func() {
+			t.busy = false
+
+			if err != nil {
+				t.message, t.failed = err.Error(), true
+			} else {
+				t.message, t.failed = reply.Message, false
+			}
+
+			if !t.loading {
+				t.load(ctx)
+			}
+		}
+	}()
+}
+
+// load refreshes the status and the log tail in background.
+func (t *tui) load(ctx context.Context) {
+	t.loading = true
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		attached := true
+
+		var status *daemonStatus
+
+		reply, err := t.client.do(ctx, http.MethodGet, "/v1/status", nil)
+		if err == nil {
+			status = reply.Status
+		} else if errors.Is(err, errDaemonNotRunning) {
+			attached = false
+			status, err = localStatus(t.dataDir, t.nas)
+		}
+
+		lines := t.tail()
+
+		t.updates <- func() {
+			t.loading = false
+			t.loadedAt = time.Now()
+			t.attached = attached
+			t.loadErr = err
+			t.logLines = lines
+
+			if status != nil {
+				t.status = status
+			}
+		}
+	}()
+}
+
+// localStatus reads the status from the data dir, the state of the NAS is unknown.
+func localStatus(dataDir, nas string) (*daemonStatus, error) {
+	s := &daemonStatus{
+		Version: version,
+		NAS:     nas,
+		Targets: []targetStatus{{Address: nas, Error: "daemon is not running"}},
+	}
+
+	acmeCerts, err := listCertificates(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := listImportedCertificates(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ob, err := loadOutbox(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(source string, c cert) {
+		dc := notify.DigestCert{
+			Group:     c.name,
+			Domains:   c.DNSNames,
+			Source:    source,
+			NotBefore: c.NotBefore,
+			NotAfter:  c.NotAfter,
+			NAS:       nas,
+			NASState:  nasStateUnknown,
+		}
+
+		if op, ok := ob.get(c.name); ok {
+			dc.NASState = nasStatePending
+			dc.LastError = op.LastError
+		}
+
+		s.Certificates = append(s.Certificates, dc)
+	}
+
+	for _, c := range acmeCerts {
+		add("acme", c)
+	}
+
+	for _, c := range imported {
+		add("imported", c)
+	}
+
+	pendings, err := listPendingCertificates(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range pendings {
+		s.Pending = append(s.Pending, p.name)
+	}
+
+	s.Outbox = ob.due(time.Now(), true)
+
+	return s, nil
+}
+
+// tail returns the last lines of the log file, or the journal if no log file is written.
+func (t *tui) tail() []string {
+	if t.logFile == "" {
+		entries, err := openJournal(t.dataDir).entries()
+		if err != nil {
+			return []string{err.Error()}
+		}
+
+		lines := make([]string, 0, len(entries))
+		for _, e := range entries {
+			lines = append(lines, strings.Join([]string{e.Time.Local().Format(time.DateTime), e.Type, orDash(e.Group), e.detailString()}, "  "))
+		}
+
+		return lines
+	}
+
+	f, err := os.Open(t.logFile)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	offset := max(fi.Size()-tuiLogTail, 0)
+
+	data := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return []string{err.Error()}
+	}
+
+	// the first line may be cut
+	if offset > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	var lines []string
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+
+	return lines
+}
+
+func (t *tui) draw() {
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height <= 0 {
+		height = 24
+	}
+
+	var lines []string
+
+	mode := ansiGreen + "attached to daemon" + ansiReset
+	if !t.attached {
+		mode = ansiYellow + "read-only, daemon is not running" + ansiReset
+	}
+
+	header := ansiBold + "fnos-acme " + version + ansiReset + "  " + mode
+
+	if t.status != nil && t.attached {
+		header += "  next check " + t.status.NextCheck.Local().Format(time.TimeOnly) + " (in " + countdown(time.Until(t.status.NextCheck)) + ")"
+	}
+
+	lines = append(lines, header, "")
+
+	if t.loadErr != nil {
+		lines = append(lines, ansiRed+t.loadErr.Error()+ansiReset, "")
+	}
+
+	if t.status != nil {
+		group := t.selectedGroup()
+
+		rows := [][]tuiCell{{{text: "GROUP"}, {text: "SOURCE"}, {text: "EXPIRES"}, {text: "LEFT"}, {text: "NAS"}, {text: "NAS CERT"}, {text: "LAST ERROR"}}}
+
+		for _, c := range t.status.Certificates {
+			nasCert := "-"
+			if c.NASCert != 0 {
+				nasCert = fmt.Sprint(c.NASCert)
+			}
+
+			rows = append(rows, []tuiCell{
+				{text: c.Group},
+				{text: c.Source},
+				{text: c.NotAfter.Local().Format(time.DateTime)},
+				{text: countdown(time.Until(c.NotAfter)), color: expiryColor(c.NotAfter)},
+				{text: c.NASState, color: nasStateColor(c.NASState)},
+				{text: nasCert},
+				{text: orDash(c.LastError)},
+			})
+		}
+
+		for i, row := range renderTable(rows) {
+			switch {
+			case i == 0:
+				row = ansiDim + row + ansiReset
+			case t.status.Certificates[i-1].Group == group:
+				row = ansiInvert + row + ansiReset
+			}
+
+			lines = append(lines, row)
+		}
+
+		for _, p := range t.status.Pending {
+			lines = append(lines, ansiYellow+p+" has a new certificate waiting for approval"+ansiReset)
+		}
+
+		for _, op := range t.status.Outbox {
+			lines = append(lines, fmt.Sprintf("%s%s deployment pending, attempt %d, next %s%s", ansiYellow, op.Group, op.Attempts, op.NextAttempt.Local().Format(time.TimeOnly), ansiReset))
+		}
+
+		for _, target := range t.status.Targets {
+			lines = append(lines, "")
+
+			state := ansiRed + "disconnected" + ansiReset
+			if target.Connected {
+				state = ansiGreen + "connected" + ansiReset
+			}
+
+			lines = append(lines, ansiBold+"NAS "+target.Address+ansiReset+"  "+state)
+
+			if target.Error != "" {
+				lines = append(lines, ansiDim+target.Error+ansiReset)
+				continue
+			}
+
+			rows := [][]tuiCell{{{text: "ID"}, {text: "DOMAIN"}, {text: "STATUS"}, {text: "VALID TO"}, {text: "LEFT"}, {text: "DEFAULT"}, {text: "SOURCE"}}}
+
+			for _, c := range target.Certificates {
+				rows = append(rows, []tuiCell{
+					{text: fmt.Sprint(c.ID)},
+					{text: c.Domain},
+					{text: c.Status},
+					{text: c.ValidTo.Local().Format(time.DateTime)},
+					{text: countdown(time.Until(c.ValidTo)), color: expiryColor(c.ValidTo)},
+					{text: fmt.Sprint(c.Default)},
+					{text: c.Source},
+				})
+			}
+
+			for i, row := range renderTable(rows) {
+				if i == 0 {
+					row = ansiDim + row + ansiReset
+				}
+
+				lines = append(lines, row)
+			}
+		}
+	}
+
+	footer := []string{"", ansiDim + "↑/↓ select  r renew  d deploy  b rollback  c check  u refresh  q quit" + ansiReset}
+	if t.message != "" {
+		color := ""
+		if t.failed {
+			color = ansiRed
+		}
+
+		footer = append([]string{"", color + t.message + ansiReset}, footer...)
+	}
+
+	// the log tail fills the remaining rows
+	if rows := height - len(lines) - len(footer) - 2; rows > 0 {
+		title := "LOG"
+		if t.logFile == "" {
+			title = "JOURNAL"
+		}
+
+		lines = append(lines, "", ansiDim+title+ansiReset)
+		lines = append(lines, t.logLines[max(len(t.logLines)-rows, 0):]...)
+	}
+
+	lines = append(lines, footer...)
+	lines = lines[:min(len(lines), height)]
+
+	var b strings.Builder
+
+	b.WriteString("\x1b[H")
+
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+
+	b.WriteString("\x1b[J")
+
+	fmt.Print(b.String())
+}
+
+type tuiCell struct {
+	text  string
+	color string
+}
+
+// renderTable pads the cells to the column width, the color applies to the cell text.
+func renderTable(rows [][]tuiCell) []string {
+	var widths []int
+
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.text))
+		}
+	}
+
+	lines := make([]string, 0, len(rows))
+
+	for _, row := range rows {
+		var b strings.Builder
+
+		for i, cell := range row {
+			if cell.color != "" {
+				b.WriteString(cell.color + cell.text + ansiReset)
+			} else {
+				b.WriteString(cell.text)
+			}
+
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.text)+2))
+			}
+		}
+
+		lines = append(lines, b.String())
+	}
+
+	return lines
+}
+
+// countdown formats the duration as days, hours and minutes.
+func countdown(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	if days > 0 {
+		return fmt.Sprintf("%s%dd %02dh %02dm", sign, days, hours, minutes)
+	}
+
+	return fmt.Sprintf("%s%dh %02dm %02ds", sign, hours, minutes, int(d%time.Minute/time.Second))
+}
+
+func expiryColor(notAfter time.Time) string {
+	switch left := time.Until(notAfter); {
+	case left <= 7*24*time.Hour:
+		return ansiRed
+	case left <= 30*24*time.Hour:
+		return ansiYellow
+	default:
+		return ansiGreen
+	}
+}
+
+func nasStateColor(state string) string {
+	switch state {
+	case nasStateDeployed:
+		return ansiGreen
+	case nasStateMissing:
+		return ansiRed
+	case nasStateStale, nasStatePending:
+		return ansiYellow
+	default:
+		return ""
+	}
+}
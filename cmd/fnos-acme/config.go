@@ -0,0 +1,210 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgConfig = "config"
+)
+
+// readConfigFile reads the KEY=VALUE lines of the config file, the keys are the environment variables of the flags.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	values := make(map[string]string)
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: missing =", path, n)
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		values[key] = value
+	}
+
+	return values, sc.Err()
+}
+
+// configValue is a KEY=VALUE line of the config file.
+type configValue struct {
+	key   string
+	value string
+}
+
+// writeConfigFile writes the values in the format of readConfigFile, values with surrounding spaces or quotes are quoted.
+func writeConfigFile(path, header string, values []configValue) error {
+	var b strings.Builder
+
+	for _, line := range strings.Split(header, "\n") {
+		fmt.Fprintf(&b, "# %s\n", line)
+	}
+
+	b.WriteString("\n")
+
+	for _, v := range values {
+		value := v.value
+		if strings.TrimSpace(value) != value || strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			value = `"` + value + `"`
+		}
+
+		fmt.Fprintf(&b, "%s=%s\n", v.key, value)
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// applyConfigFile sets the flags of the command from the config file and exports the other values,
+// flags given on the command line or in the environment take precedence.
+func applyConfigFile(ctx context.Context, c *cli.Command) (context.Context, error) {
+	path := c.String(flgConfig)
+	if path == "" {
+		return ctx, nil
+	}
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		return ctx, fmt.Errorf("read config file: %w", err)
+	}
+
+	for _, fl := range c.Flags {
+		envFlag, ok := fl.(interface{ GetEnvVars() []string })
+		if !ok {
+			continue
+		}
+
+		name := fl.Names()[0]
+		if c.IsSet(name) {
+			continue
+		}
+
+		for _, key := range envFlag.GetEnvVars() {
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+
+			if err := c.Set(name, value); err != nil {
+				return ctx, fmt.Errorf("config %s: %w", key, err)
+			}
+
+			break
+		}
+	}
+
+	return ctx, exportConfigEnv(c.Root(), values)
+}
+
+// configEnv are the variables exported from the config file, unlike the ones from the environment
+// they are updated or unset when the config file is read again.
+var configEnv = make(map[string]string)
+
+// exportedConfigEnv returns a copy of the variables exported from the config file.
+func exportedConfigEnv() map[string]string {
+	return maps.Clone(configEnv)
+}
+
+// saveConfigEnv returns a function restoring the variables exported from the config file as they are now,
+// it undoes the export of a config file which turns out to be invalid.
+func saveConfigEnv() func() {
+	saved := exportedConfigEnv()
+
+	return func() {
+		for key := range configEnv {
+			if _, ok := saved[key]; !ok {
+				os.Unsetenv(key)
+			}
+		}
+
+		for key, value := range saved {
+			os.Setenv(key, value)
+		}
+
+		configEnv = saved
+	}
+}
+
+// exportConfigEnv exports the values which are not of any flag, e.g. the credentials of the dns provider
+// which are read from the environment.
+func exportConfigEnv(root *cli.Command, values map[string]string) error {
+	flagKeys := make(map[string]bool)
+
+	var walk func(cmd *cli.Command)
+	walk = func(cmd *cli.Command) {
+		for _, fl := range cmd.Flags {
+			if envFlag, ok := fl.(interface{ GetEnvVars() []string }); ok {
+				for _, key := range envFlag.GetEnvVars() {
+					flagKeys[key] = true
+				}
+			}
+		}
+
+		for _, sub := range cmd.Commands {
+			walk(sub)
+		}
+	}
+
+	walk(root)
+
+	for key, value := range values {
+		if flagKeys[key] {
+			continue
+		}
+
+		if _, ok := os.LookupEnv(key); ok {
+			if _, exported := configEnv[key]; !exported {
+				continue
+			}
+		}
+
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("config %s: %w", key, err)
+		}
+
+		configEnv[key] = value
+	}
+
+	// the values removed from the config file are not kept in the environment
+	for key := range configEnv {
+		if _, ok := values[key]; ok && !flagKeys[key] {
+			continue
+		}
+
+		if err := os.Unsetenv(key); err != nil {
+			return fmt.Errorf("config %s: %w", key, err)
+		}
+
+		delete(configEnv, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"maps"
+	"os"
+	"testing"
+)
+
+func TestSaveConfigEnvRestore(t *testing.T) {
+	const (
+		kept  = "FNOS_ACME_TEST_TOKEN"
+		added = "FNOS_ACME_TEST_SECRET"
+	)
+
+	// registered for cleanup, then unset so they are exported from the config file
+	t.Setenv(kept, "")
+	t.Setenv(added, "")
+	os.Unsetenv(kept)
+	os.Unsetenv(added)
+
+	prev := configEnv
+	configEnv = make(map[string]string)
+	t.Cleanup(func() { configEnv = prev })
+
+	root := newRootCommand()
+
+	if err := exportConfigEnv(root, map[string]string{kept: "old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	restore := saveConfigEnv()
+
+	if err := exportConfigEnv(root, map[string]string{kept: "new", added: "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if os.Getenv(kept) != "new" || os.Getenv(added) != "x" {
+		t.Fatal("config file not exported")
+	}
+
+	restore()
+
+	if v := os.Getenv(kept); v != "old" {
+		t.Fatalf("%s = %q, want old", kept, v)
+	}
+
+	if _, ok := os.LookupEnv(added); ok {
+		t.Fatalf("%s is still set", added)
+	}
+
+	if want := map[string]string{kept: "old"}; !maps.Equal(exportedConfigEnv(), want) {
+		t.Fatalf("exported = %v, want %v", exportedConfigEnv(), want)
+	}
+}
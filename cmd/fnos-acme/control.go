@@ -0,0 +1,413 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+)
+
+const controlSocket = "control.sock"
+
+const (
+	controlStatus   = "status"
+	controlCheck    = "check"
+	controlRenew    = "renew"
+	controlReload   = "reload"
+	controlDeploy   = "deploy"
+	controlRollback = "rollback"
+)
+
+var (
+	errUnknownGroup  = errors.New("unknown group")
+	errUnknownTarget = errors.New("unknown target")
+	errNotRenewable  = errors.New("can not be renewed")
+	errNoRollback    = errors.New("can not be rolled back")
+)
+
+// controlRequest is a command received from the control socket, it is executed by the run loop.
+type controlRequest struct {
+	Op     string `json:"op"`
+	Group  string `json:"group,omitempty"`
+	Target string `json:"target,omitempty"`
+
+	reply chan controlReply
+}
+
+type controlReply struct {
+	Status  *daemonStatus `json:"status,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Error   string        `json:"error,omitempty"`
+
+	err error
+}
+
+// status maps the error of the reply to http status.
+func (r controlReply) status() int {
+	switch {
+	case errors.Is(r.err, errUnknownGroup):
+		return http.StatusNotFound
+	case errors.Is(r.err, errUnknownTarget), errors.Is(r.err, errNotRenewable), errors.Is(r.err, errNoRollback):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// targetStatus is the state of a NAS the certificates are deployed to.
+type targetStatus struct {
+	Address      string           `json:"address"`
+	Connected    bool             `json:"connected"`
+	Certificates []nasCertificate `json:"certificates"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// nasCertificate is a certificate copy in the NAS as listed by GetCertList.
+type nasCertificate struct {
+	ID       int       `json:"id"`
+	Domain   string    `json:"domain"`
+	SAN      string    `json:"san"`
+	IssuedBy string    `json:"issuedBy"`
+	Status   string    `json:"status"`
+	ValidTo  time.Time `json:"validTo"`
+	Default  bool      `json:"default"`
+	Source   string    `json:"source"`
+}
+
+// daemonStatus is the state of the running daemon reported by ctl status.
+type daemonStatus struct {
+	notify.Digest
+	Version   string         `json:"version"`
+	NAS       string         `json:"nas"`
+	NextCheck time.Time      `json:"nextCheck"`
+	Targets   []targetStatus `json:"targets"`
+	Pending   []string       `json:"pendingApproval,omitempty"`
+	Outbox    []outboxOp     `json:"outbox,omitempty"`
+}
+
+func controlSocketPath(dataDir string) string {
+	return filepath.Join(dataDir, controlSocket)
+}
+
+// serveControl serves the control api on the unix socket, only the owner of the daemon can connect it.
+func serveControl(ctx context.Context, path string, requests chan<- controlRequest) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/status", controlHandler(ctx, requests, controlStatus))
+	mux.Handle("POST /v1/check", controlHandler(ctx, requests, controlCheck))
+	mux.Handle("POST /v1/renew", controlHandler(ctx, requests, controlRenew))
+	mux.Handle("POST /v1/reload", controlHandler(ctx, requests, controlReload))
+	mux.Handle("POST /v1/deploy", controlHandler(ctx, requests, controlDeploy))
+	mux.Handle("POST /v1/rollback", controlHandler(ctx, requests, controlRollback))
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown control server failed", "err", err)
+		}
+	}()
+
+	slog.Info("serving control socket", "path", path)
+
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// controlHandler passes the request to the run loop and writes back the reply.
+func controlHandler(ctx context.Context, requests chan<- controlRequest, op string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := controlRequest{Op: op}
+
+		if r.Method == http.MethodPost && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, controlReply{Error: err.Error()})
+				return
+			}
+		}
+
+		req.Op = op
+
+		if reply, status, ok := submitControl(ctx, r, requests, req); ok {
+			writeJSON(w, status, reply)
+		}
+	}
+}
+
+// submitControl passes the request to the run loop and waits for the reply with its http status,
+// ok is false if the caller has gone.
+func submitControl(ctx context.Context, r *http.Request, requests chan<- controlRequest, req controlRequest) (controlReply, int, bool) {
+	if (req.Op == controlRenew || req.Op == controlDeploy || req.Op == controlRollback) && req.Group == "" {
+		return controlReply{Error: "group is required"}, http.StatusBadRequest, true
+	}
+
+	req.reply = make(chan controlReply, 1)
+
+	select {
+	case requests <- req:
+	case <-r.Context().Done():
+		return controlReply{}, 0, false
+	case <-ctx.Done():
+		return controlReply{Error: "daemon is shutting down"}, http.StatusServiceUnavailable, true
+	}
+
+	select {
+	case reply := <-req.reply:
+		if reply.Error != "" {
+			return reply, reply.status(), true
+		}
+
+		return reply, http.StatusOK, true
+	case <-r.Context().Done():
+		return controlReply{}, 0, false
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// control executes the request from the control socket.
+func (u *updater) control(ctx context.Context, req controlRequest) controlReply {
+	if req.Group != "" {
+		ctx = withLogAttrs(ctx, slog.String("group", req.Group))
+	}
+
+	if req.Op != controlStatus {
+		slog.InfoContext(ctx, "control request", "op", req.Op, "target", req.Target)
+	}
+
+	var (
+		reply controlReply
+		err   error
+	)
+
+	switch req.Op {
+	case controlStatus:
+		reply.Status, err = u.status(ctx)
+	case controlCheck:
+		err = u.checkAndUpdate(ctx)
+		reply.Message = "certificates checked"
+	case controlRenew:
+		err = u.renew(ctx, req.Group)
+		reply.Message = fmt.Sprintf("certificate %s renewed", req.Group)
+	case controlReload:
+		u.flushOutbox(ctx, true)
+		err = u.checkAndUpdate(ctx)
+		reply.Message = "config and certificates reloaded"
+	case controlDeploy:
+		reply.Message, err = u.deploy(ctx, req.Group, req.Target)
+	case controlRollback:
+		reply.Message, err = u.rollback(ctx, req.Group)
+	default:
+		err = fmt.Errorf("unknown op %q", req.Op)
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "control request failed", "op", req.Op, "err", err)
+
+		// mistakes in the request are reported to the caller only
+		if req.Op != controlStatus && !errors.Is(err, errUnknownGroup) && !errors.Is(err, errUnknownTarget) &&
+			!errors.Is(err, errNotRenewable) && !errors.Is(err, errNoRollback) {
+			u.notifyFailed(ctx, req.Group, err)
+		}
+
+		return controlReply{Error: err.Error(), err: err}
+	}
+
+	return reply
+}
+
+func (u *updater) status(ctx context.Context) (*daemonStatus, error) {
+	d, err := u.buildDigest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pendings, err := listPendingCertificates(u.dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &daemonStatus{
+		Digest:    d,
+		Version:   version,
+		NAS:       u.nas,
+		NextCheck: u.nextCheck,
+		Targets:   []targetStatus{u.targetStatus(ctx)},
+		Outbox:    u.outbox.due(time.Now(), true),
+	}
+
+	for _, p := range pendings {
+		s.Pending = append(s.Pending, p.name)
+	}
+
+	return s, nil
+}
+
+func (u *updater) targetStatus(ctx context.Context) targetStatus {
+	t := targetStatus{
+		Address:   u.nas,
+		Connected: u.trimClient.LoggedIn(),
+	}
+
+	certList, err := u.trimClient.Main().RemoteAccessService().GetCertList(ctx)
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+
+	for _, c := range certList.Data {
+		t.Certificates = append(t.Certificates, nasCertificate{
+			ID:       c.ID,
+			Domain:   c.Domain,
+			SAN:      c.San,
+			IssuedBy: c.IssuedBy,
+			Status:   c.Status,
+			ValidTo:  remoteCertValidTo(&c),
+			Default:  c.IsDefault == 1,
+			Source:   c.Source,
+		})
+	}
+
+	return t
+}
+
+// renew obtains a new certificate of the group, only the acme certificate can be renewed.
+func (u *updater) renew(ctx context.Context, group string) error {
+	if group != u.domains[0] {
+		imported, err := listImportedCertificates(u.dataDir)
+		if err != nil {
+			return err
+		}
+
+		if slices.ContainsFunc(imported, func(c cert) bool { return c.name == group }) {
+			return fmt.Errorf("certificate %s is imported and %w, replace it in the import dir", group, errNotRenewable)
+		}
+
+		return fmt.Errorf("%w %s", errUnknownGroup, group)
+	}
+
+	slog.InfoContext(ctx, "renew certificate on request")
+
+	obtained, err := u.obtainIfNotPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !obtained {
+		return fmt.Errorf("certificate %s is waiting for approval and %w", group, errNotRenewable)
+	}
+
+	return nil
+}
+
+// deploy deploys the certificate of the group to the target, which must be the configured nas.
+func (u *updater) deploy(ctx context.Context, group, target string) (string, error) {
+	if target != "" && target != u.nas {
+		return "", fmt.Errorf("%w %s, the daemon deploys to %s", errUnknownTarget, target, u.nas)
+	}
+
+	certs, err := listManagedCertificates(u.dataDir)
+	if err != nil {
+		return "", err
+	}
+
+	idx := slices.IndexFunc(certs, func(c cert) bool { return c.name == group })
+	if idx < 0 {
+		return "", fmt.Errorf("%w %s", errUnknownGroup, group)
+	}
+
+	c := certs[idx]
+
+	if err := u.deployCert(ctx, certHookEnv(u.dataDir, c), c, true); err != nil {
+		return "", err
+	}
+
+	remoteCert, err := findRemoteCert(ctx, u.trimClient, group)
+	if err != nil {
+		return "", err
+	}
+
+	if remoteCert == nil || remoteCertStale(remoteCert, c) {
+		return fmt.Sprintf("certificate %s replacement on %s deferred to maintenance window", group, u.nas), nil
+	}
+
+	return fmt.Sprintf("certificate %s deployed to %s, id %d", group, u.nas, remoteCert.ID), nil
+}
+
+// rollback restores the previous certificate of the group and deploys it,
+// only the acme certificate keeps its previous one and an expired one is never restored.
+func (u *updater) rollback(ctx context.Context, group string) (string, error) {
+	if group != u.domains[0] {
+		return "", fmt.Errorf("%w %s", errUnknownGroup, group)
+	}
+
+	previous, err := hasPreviousCertificate(u.dataDir, group)
+	if err != nil {
+		return "", err
+	}
+
+	if !previous {
+		return "", fmt.Errorf("certificate %s has no previous certificate and %w", group, errNoRollback)
+	}
+
+	pCert, err := readPreviousCertificate(u.dataDir, group)
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(pCert.NotAfter) {
+		return "", fmt.Errorf("previous certificate of %s expired at %s and %w", group, pCert.NotAfter.Format(time.RFC3339), errNoRollback)
+	}
+
+	slog.InfoContext(ctx, "roll back certificate on request")
+
+	if err := rollbackCertificate(u.dataDir, group); err != nil {
+		return "", err
+	}
+
+	u.journal.record(journalEntry{Type: journalCertRolledBack, Group: group})
+
+	return u.deploy(ctx, group, "")
+}
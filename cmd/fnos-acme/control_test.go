@@ -0,0 +1,51 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+func TestRollbackExpiredPrevious(t *testing.T) {
+	const group = "example.com"
+
+	dataDir := t.TempDir()
+
+	currentPEM, currentKey := newTestCertificate(t, group, time.Now().Add(60*24*time.Hour))
+	previousPEM, previousKey := newTestCertificate(t, group, time.Now().Add(-time.Hour))
+
+	for dir, res := range map[string]*certificate.Resource{
+		filepath.Join(dataDir, "certificates"): {Domain: group, Certificate: currentPEM, PrivateKey: currentKey},
+		previousDir(dataDir):                   {Domain: group, Certificate: previousPEM, PrivateKey: previousKey},
+	} {
+		if err := writeCertificate(dir, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &updater{dataDir: dataDir, domains: []string{group}}
+
+	if _, err := u.rollback(context.Background(), group); !errors.Is(err, errNoRollback) {
+		t.Fatalf("err = %v, want %v", err, errNoRollback)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "certificates", group+certExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, currentPEM) {
+		t.Fatal("the current certificate is replaced by the expired one")
+	}
+}
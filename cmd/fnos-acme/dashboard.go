@@ -0,0 +1,25 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// dashboardHandler serves the embedded web dashboard.
+func dashboardHandler() http.Handler {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.FileServerFS(sub)
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+)
+
+const (
+	// digestErrorWindow is how long a failure is listed in the digest.
+	digestErrorWindow = 7 * 24 * time.Hour
+	digestMaxErrors   = 50
+)
+
+const (
+	nasStateDeployed = "deployed"
+	nasStateStale    = "stale"
+	nasStateMissing  = "missing"
+	nasStatePending  = "pending"
+	nasStateUnknown  = "unknown"
+)
+
+// recordFailure keeps the failure for the digest.
+func (u *updater) recordFailure(e notify.Event) {
+	u.failures = append(u.failures, e)
+
+	if len(u.failures) > digestMaxErrors {
+		u.failures = slices.Delete(u.failures, 0, len(u.failures)-digestMaxErrors)
+	}
+}
+
+func (u *updater) buildDigest(ctx context.Context) (notify.Digest, error) {
+	now := time.Now()
+	d := notify.Digest{Time: now}
+
+	acmeCerts, err := listCertificates(u.dataDir)
+	if err != nil {
+		return d, err
+	}
+
+	imported, err := listImportedCertificates(u.dataDir)
+	if err != nil {
+		return d, err
+	}
+
+	add := func(source string, c cert) {
+		dc := notify.DigestCert{
+			Group:     c.name,
+			Domains:   c.DNSNames,
+			Source:    source,
+			NotBefore: c.NotBefore,
+			NotAfter:  c.NotAfter,
+			NAS:       u.nas,
+			NASState:  nasStateUnknown,
+		}
+
+		remoteCert, err := findRemoteCert(ctx, u.trimClient, c.name)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "query fnos certificate for digest failed", "group", c.name, "err", err)
+		case remoteCert == nil:
+			dc.NASState = nasStateMissing
+		default:
+			dc.NASCert = remoteCert.ID
+			dc.NASNotAfter = remoteCertValidTo(remoteCert)
+			dc.NASState = nasStateDeployed
+
+			if remoteCertStale(remoteCert, c) {
+				dc.NASState = nasStateStale
+			}
+		}
+
+		if op, ok := u.outbox.get(c.name); ok {
+			dc.NASState = nasStatePending
+			dc.LastError = op.LastError
+		}
+
+		d.Certificates = append(d.Certificates, dc)
+	}
+
+	for _, c := range acmeCerts {
+		add("acme", c)
+	}
+
+	for _, c := range imported {
+		add("imported", c)
+	}
+
+	for _, e := range u.failures {
+		if now.Sub(e.Time) < digestErrorWindow {
+			d.Errors = append(d.Errors, e)
+		}
+	}
+
+	return d, nil
+}
+
+func (u *updater) sendDigest(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "digest")
+	defer span.End()
+
+	d, err := u.buildDigest(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "build digest failed", "err", err)
+		return
+	}
+
+	if err := u.notifier.SendDigest(ctx, d); err != nil {
+		return
+	}
+
+	slog.InfoContext(ctx, "digest sent", "certificates", len(d.Certificates), "errors", len(d.Errors))
+}
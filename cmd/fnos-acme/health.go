@@ -0,0 +1,145 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim"
+)
+
+// livenessGrace is added to the check interval before the run loop is considered stuck.
+const livenessGrace = 5 * time.Minute
+
+// healthState tracks the daemon state for the liveness and readiness probes.
+type healthState struct {
+	mu           sync.RWMutex
+	dataDir      string
+	interval     time.Duration
+	criticalDays int
+	lastTick     time.Time
+	trimClient   *trim.Client
+	registered   bool
+}
+
+type probeResult struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func newHealthState(dataDir string, interval time.Duration, criticalDays int) *healthState {
+	return &healthState{
+		dataDir:      dataDir,
+		interval:     interval,
+		criticalDays: criticalDays,
+		lastTick:     time.Now(),
+	}
+}
+
+// tick records the run loop is alive.
+func (h *healthState) tick() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastTick = time.Now()
+}
+
+func (h *healthState) setTrimClient(client *trim.Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.trimClient = client
+}
+
+func (h *healthState) setInterval(interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.interval = interval
+}
+
+func (h *healthState) setRegistered() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.registered = true
+}
+
+func (h *healthState) liveness() (bool, map[string]string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	since := time.Since(h.lastTick)
+	if since > h.interval+livenessGrace {
+		return false, map[string]string{"runLoop": fmt.Sprintf("no tick for %s", since.Round(time.Second))}
+	}
+
+	return true, map[string]string{"runLoop": "ok"}
+}
+
+func (h *healthState) readiness() (bool, map[string]string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ready := true
+	checks := make(map[string]string)
+
+	if h.trimClient != nil && h.trimClient.LoggedIn() {
+		checks["nas"] = "ok"
+	} else {
+		ready = false
+		checks["nas"] = "not logged in"
+	}
+
+	if h.registered {
+		checks["acmeAccount"] = "ok"
+	} else {
+		ready = false
+		checks["acmeAccount"] = "not registered"
+	}
+
+	certs, err := listManagedCertificates(h.dataDir)
+	if err != nil {
+		ready = false
+		checks["certificates"] = err.Error()
+		return ready, checks
+	}
+
+	checks["certificates"] = "ok"
+
+	critical := time.Now().AddDate(0, 0, h.criticalDays)
+	for _, c := range certs {
+		if critical.After(c.NotAfter) {
+			ready = false
+			checks["certificates"] = fmt.Sprintf("%s expires at %s", c.name, c.NotAfter.Format(time.RFC3339))
+			break
+		}
+	}
+
+	return ready, checks
+}
+
+func probeHandler(probe func() (bool, map[string]string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, checks := probe()
+
+		result := probeResult{Status: "ok", Checks: checks}
+		status := http.StatusOK
+
+		if !ok {
+			result.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
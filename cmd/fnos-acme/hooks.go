@@ -0,0 +1,216 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	hookPreIssue   = "pre-issue"
+	hookPostIssue  = "post-issue"
+	hookPostDeploy = "post-deploy"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+type hooks struct {
+	commands map[string]string
+	timeout  time.Duration
+}
+
+// hookEnv describes the certificate a hook is launched for.
+type hookEnv struct {
+	group     string
+	domains   []string
+	dataDir   string
+	certDir   string
+	nasCertID int
+	err       error
+}
+
+func hooksFromCommand(c *cli.Command) *hooks {
+	return &hooks{
+		commands: map[string]string{
+			hookPreIssue:   c.String(flgPreIssueHook),
+			hookPostIssue:  c.String(flgPostIssueHook),
+			hookPostDeploy: c.String(flgPostDeployHook),
+		},
+		timeout: c.Duration(flgHookTimeout),
+	}
+}
+
+func certHookEnv(dataDir string, c cert) hookEnv {
+	return hookEnv{
+		group:   c.name,
+		domains: c.DNSNames,
+		dataDir: dataDir,
+		certDir: c.dir,
+	}
+}
+
+func (e hookEnv) withResult(nasCertID int, err error) hookEnv {
+	e.nasCertID = nasCertID
+	e.err = err
+	return e
+}
+
+func (e hookEnv) environ(name string) []string {
+	certDir := e.certDir
+	if certDir == "" {
+		certDir = filepath.Join(e.dataDir, "certificates")
+	}
+
+	outcome := outcomeSuccess
+	errMsg := ""
+	if e.err != nil {
+		outcome = outcomeFailure
+		errMsg = e.err.Error()
+	}
+
+	env := []string{
+		"FNOS_ACME_HOOK=" + name,
+		"FNOS_ACME_GROUP=" + e.group,
+		"FNOS_ACME_DOMAINS=" + strings.Join(e.domains, ","),
+		"FNOS_ACME_CERT_PATH=" + filepath.Join(certDir, e.group+certExt),
+		"FNOS_ACME_KEY_PATH=" + filepath.Join(certDir, e.group+keyExt),
+		"FNOS_ACME_ISSUER_PATH=" + filepath.Join(certDir, e.group+issuerExt),
+		"FNOS_ACME_OUTCOME=" + outcome,
+		"FNOS_ACME_ERROR=" + errMsg,
+	}
+
+	if e.nasCertID != 0 {
+		env = append(env, "FNOS_ACME_NAS_CERT_ID="+strconv.Itoa(e.nasCertID))
+	}
+
+	return env
+}
+
+// run launches the named hook and returns its error, the caller should abort on failure.
+func (h *hooks) run(ctx context.Context, name string, env hookEnv) (err error) {
+	command := h.commands[name]
+	if command == "" {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "hook "+name, trace.WithAttributes(attribute.String("group", env.group)))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// the command is executed without a shell, as the container image has none
+	args, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env.environ(name)...)
+	// children of the command may keep the output open after it is killed on timeout
+	cmd.WaitDelay = time.Second
+
+	slog.InfoContext(ctx, "run hook", "hook", name)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		slog.InfoContext(ctx, "hook output", "hook", name, "output", strings.TrimSpace(string(output)))
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.New("hook timed out")
+	}
+
+	return err
+}
+
+// splitCommand splits the hook command into arguments like a shell does, arguments may be quoted by
+// single or double quotes and characters escaped by backslash. Pipes, redirections and variables are not
+// supported, a script should be used for them.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			// only the quote and backslash are escaped in double quotes
+			if quote == '"' && r != '"' && r != '\\' {
+				arg.WriteRune('\\')
+			}
+
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in hook command %q", command)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("empty hook command")
+	}
+
+	return args, nil
+}
+
+// notify launches the named hook and only logs its error, used for post hooks.
+func (h *hooks) notify(ctx context.Context, name string, env hookEnv) {
+	if err := h.run(ctx, name, env); err != nil {
+		slog.ErrorContext(ctx, "run hook failed", "hook", name, "err", err)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"/usr/bin/notify", []string{"/usr/bin/notify"}},
+		{"  reload   nginx\t-s  ", []string{"reload", "nginx", "-s"}},
+		{`curl -d 'group changed' http://hook`, []string{"curl", "-d", "group changed", "http://hook"}},
+		{`echo "a \"quoted\" \$x" 'it''s'`, []string{"echo", `a "quoted" \$x`, "its"}},
+		{`cp a\ b c`, []string{"cp", "a b", "c"}},
+		{`echo '' ""`, []string{"echo", "", ""}},
+		{`echo 'no \escape'`, []string{"echo", `no \escape`}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitCommand(tt.command)
+		if err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	for _, command := range []string{"", "   ", `echo 'open`, `echo "open`, `echo trailing\`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("%q: want error", command)
+		}
+	}
+}
+
+// TestHookHelperProcess is the hook launched by TestHookRun, it records its arguments and environment.
+func TestHookHelperProcess(t *testing.T) {
+	out := os.Getenv("FNOS_ACME_TEST_HOOK_OUT")
+	if out == "" {
+		t.Skip("launched as hook only")
+	}
+
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+
+	data, _ := json.Marshal(map[string]any{
+		"args":  args,
+		"hook":  os.Getenv("FNOS_ACME_HOOK"),
+		"group": os.Getenv("FNOS_ACME_GROUP"),
+	})
+
+	if err := os.WriteFile(out, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if slices.Contains(args, "fail") {
+		os.Exit(3)
+	}
+}
+
+// TestHookRun runs the test binary as hook, so no shell is required on the host.
+func TestHookRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.json")
+	t.Setenv("FNOS_ACME_TEST_HOOK_OUT", out)
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &hooks{
+		commands: map[string]string{
+			hookPostDeploy: strconv.Quote(exe) + ` -test.run=^TestHookHelperProcess$ -- 'a b' "c \"d\"" e\ f`,
+			hookPreIssue:   strconv.Quote(exe) + ` -test.run=^TestHookHelperProcess$ -- fail`,
+		},
+		timeout: time.Minute,
+	}
+
+	env := hookEnv{group: "example.com", domains: []string{"example.com"}, dataDir: t.TempDir()}
+
+	if err := h.run(context.Background(), hookPostDeploy, env); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Args  []string `json:"args"`
+		Hook  string   `json:"hook"`
+		Group string   `json:"group"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a b", `c "d"`, "e f"}; !slices.Equal(got.Args, want) {
+		t.Fatalf("args = %q, want %q", got.Args, want)
+	}
+
+	if got.Hook != hookPostDeploy || got.Group != "example.com" {
+		t.Fatalf("env = %+v", got)
+	}
+
+	if err := h.run(context.Background(), hookPreIssue, env); err == nil {
+		t.Fatal("want error of the failed hook")
+	}
+}
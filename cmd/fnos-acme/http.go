@@ -0,0 +1,65 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func newOpsHandler(health *healthState) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	mux.Handle("GET /healthz", probeHandler(health.liveness))
+	mux.Handle("GET /readyz", probeHandler(health.readiness))
+
+	return mux
+}
+
+// serveHTTP serves handler on addr until ctx is done.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	return serveHTTPS(ctx, addr, handler, nil)
+}
+
+// serveHTTPS serves handler with tls on addr until ctx is done, plain http if config is nil.
+func serveHTTPS(ctx context.Context, addr string, handler http.Handler, config *tls.Config) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         config,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown http server failed", "addr", addr, "err", err)
+		}
+	}()
+
+	slog.Info("serving http", "addr", addr, "tls", config != nil)
+
+	serve := srv.ListenAndServe
+	if config != nil {
+		serve = func() error { return srv.ListenAndServeTLS("", "") }
+	}
+
+	if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
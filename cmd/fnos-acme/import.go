@@ -0,0 +1,234 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	importSettle = 2 * time.Second
+)
+
+// watchImportDir emits the base path of cert/key pairs, or combined .pem files, created or changed in dir,
+// the existing ones are emitted at first.
+func watchImportDir(ctx context.Context, dir string) (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	pending := make(map[string]struct{})
+
+	// a combined .pem holds the key as well, so it is imported without a key file
+	for _, ext := range []string{keyExt, pemExt} {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+
+		for _, file := range files {
+			pending[strings.TrimSuffix(file, ext)] = struct{}{}
+		}
+	}
+
+	ch := make(chan string)
+
+	go func() {
+		defer watcher.Close()
+
+		// wait the files to settle down, as they may be written in pieces
+		timer := time.NewTimer(0)
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+
+				ext := filepath.Ext(event.Name)
+				if ext != certExt && ext != pemExt && ext != keyExt {
+					continue
+				}
+
+				pending[strings.TrimSuffix(event.Name, ext)] = struct{}{}
+				timer.Reset(importSettle)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Error("watch import dir failed", "dir", dir, "err", err)
+			case <-timer.C:
+				for base := range pending {
+					select {
+					case ch <- base:
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				clear(pending)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// readImportPair reads the certificate and key of base, the certificate may be a .crt or .pem file.
+// The key is nil if there is no key file, it is then taken from the certificate file.
+func readImportPair(base string) ([]byte, []byte, error) {
+	keyData, err := os.ReadFile(base + keyExt)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	for _, ext := range []string{certExt, pemExt} {
+		certData, err := os.ReadFile(base + ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		return certData, keyData, err
+	}
+
+	return nil, nil, fmt.Errorf("no certificate for %s", base)
+}
+
+// validateImport parses the certificate chain and checks the key matches the leaf. The certificate file
+// may also hold the key, so only the certificates are kept as the chain, and the key is taken from the
+// file if keyData is nil.
+func validateImport(certData, keyData []byte) (cert, error) {
+	chain, err := certcrypto.ParsePEMBundle(certData)
+	if err != nil {
+		return cert{}, err
+	}
+
+	leaf := chain[0]
+
+	if keyData == nil {
+		if keyData = findPEMPrivateKey(certData); keyData == nil {
+			return cert{}, errors.New("no private key for the certificate")
+		}
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return cert{}, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return cert{}, errors.New("unsupported private key")
+	}
+
+	pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(signer.Public()) {
+		return cert{}, errors.New("private key does not match certificate")
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		return cert{}, fmt.Errorf("certificate expired at %s", leaf.NotAfter)
+	}
+
+	name, err := certcrypto.GetCertificateMainDomain(leaf)
+	if err != nil {
+		return cert{}, err
+	}
+
+	return cert{
+		Certificate: leaf,
+		rawCert:     encodeCertificates(chain),
+		rawKey:      keyData,
+		name:        name,
+	}, nil
+}
+
+// importCertificate stores the cert/key pair of base as an imported certificate and deploys it to fnos.
+func (u *updater) importCertificate(ctx context.Context, base string) (err error) {
+	ctx, span := tracer.Start(ctx, "import", trace.WithAttributes(attribute.String("import.path", base)))
+	defer func() { endSpan(span, err) }()
+
+	certData, keyData, err := readImportPair(base)
+	if err != nil {
+		return err
+	}
+
+	c, err := validateImport(certData, keyData)
+	if err != nil {
+		return err
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("group", c.name))
+	span.SetAttributes(attribute.String("group", c.name))
+
+	if c.name == u.domains[0] {
+		return fmt.Errorf("certificate %s is managed by acme", c.name)
+	}
+
+	imported, err := listImportedCertificates(u.dataDir)
+	if err != nil {
+		return err
+	}
+
+	idx := slices.IndexFunc(imported, func(i cert) bool { return i.name == c.name })
+	if idx >= 0 && bytes.Equal(imported[idx].rawCert, c.rawCert) && bytes.Equal(imported[idx].rawKey, c.rawKey) {
+		slog.DebugContext(ctx, "imported certificate not changed")
+		return nil
+	}
+
+	if err := saveImportedCertificate(u.dataDir, &certificate.Resource{
+		Domain:      c.name,
+		Certificate: c.rawCert,
+		PrivateKey:  c.rawKey,
+	}); err != nil {
+		return err
+	}
+
+	u.journal.record(journalEntry{
+		Type:  journalCertImported,
+		Group: c.name,
+		Detail: map[string]string{
+			"path":     base,
+			"serial":   c.SerialNumber.Text(16),
+			"notAfter": c.NotAfter.Format(time.RFC3339),
+		},
+	})
+
+	c.dir = importedDir(u.dataDir)
+
+	return u.deployCert(ctx, certHookEnv(u.dataDir, c), c, true)
+}
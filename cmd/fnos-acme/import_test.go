@@ -0,0 +1,132 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+// newTestCertificate returns the PEM of a self-signed certificate of the domain and its key.
+func newTestCertificate(t *testing.T, domain string, notAfter time.Time) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certcrypto.PEMEncode(certcrypto.DERCertificateBytes(der)), certcrypto.PEMEncode(key)
+}
+
+func TestValidateImportCombinedPEM(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t, "imported.example.com", time.Now().Add(30*24*time.Hour))
+
+	for name, combined := range map[string][]byte{
+		"key first":  append(append([]byte{}, keyPEM...), certPEM...),
+		"cert first": append(append([]byte{}, certPEM...), keyPEM...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := validateImport(combined, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(c.rawCert, certPEM) {
+				t.Fatalf("certificate = %s, want only the certificate", c.rawCert)
+			}
+
+			if !bytes.Equal(c.rawKey, keyPEM) {
+				t.Fatalf("key = %s, want the key of the file", c.rawKey)
+			}
+		})
+	}
+}
+
+func TestValidateImportKeyFile(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t, "imported.example.com", time.Now().Add(30*24*time.Hour))
+	_, otherKey := newTestCertificate(t, "other.example.com", time.Now().Add(30*24*time.Hour))
+
+	// the separate key file is preferred to the one in the certificate file
+	c, err := validateImport(append(append([]byte{}, certPEM...), otherKey...), keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(c.rawCert, certPEM) || !bytes.Equal(c.rawKey, keyPEM) {
+		t.Fatal("the stored certificate or key is not the imported one")
+	}
+
+	if _, err := validateImport(certPEM, nil); err == nil {
+		t.Fatal("want error without private key")
+	}
+
+	if _, err := validateImport(certPEM, otherKey); err == nil {
+		t.Fatal("want error for a mismatched key")
+	}
+}
+
+func TestSaveImportedCombinedPEM(t *testing.T) {
+	dataDir := t.TempDir()
+	certPEM, keyPEM := newTestCertificate(t, "imported.example.com", time.Now().Add(30*24*time.Hour))
+
+	base := filepath.Join(t.TempDir(), "imported")
+	if err := os.WriteFile(base+pemExt, append(append([]byte{}, keyPEM...), certPEM...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	certData, keyData, err := readImportPair(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := validateImport(certData, keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveImportedCertificate(dataDir, &certificate.Resource{
+		Domain:      c.name,
+		Certificate: c.rawCert,
+		PrivateKey:  c.rawKey,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := os.ReadFile(filepath.Join(importedDir(dataDir), c.name+certExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(stored, []byte("PRIVATE KEY")) {
+		t.Fatalf("stored certificate holds the private key:\n%s", stored)
+	}
+}
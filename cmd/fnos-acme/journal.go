@@ -0,0 +1,345 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	journalJsonl = "journal.jsonl"
+)
+
+const (
+	journalAccountRegistered  = "account.registered"
+	journalConfigChanged      = "config.changed"
+	journalOrder              = "order"
+	journalChallengePresent   = "challenge.present"
+	journalChallengeCleanUp   = "challenge.cleanup"
+	journalCertIssued         = "certificate.issued"
+	journalCertApproved       = "certificate.approved"
+	journalCertImported       = "certificate.imported"
+	journalCertRolledBack     = "certificate.rollback"
+	journalNASUpload          = "nas.upload"
+	journalNASReplace         = "nas.replace"
+	journalNASReplaceDeferred = "nas.replace.deferred"
+)
+
+// journalTailSize is how much of the file end is read to find the last entry.
+const journalTailSize = 64 << 10
+
+// journalEntry is a line of the journal, chained by the hash of the previous entry.
+type journalEntry struct {
+	Seq       int64             `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	Group     string            `json:"group,omitempty"`
+	NAS       string            `json:"nas,omitempty"`
+	NASCertID int               `json:"nasCertId,omitempty"`
+	Detail    map[string]string `json:"detail,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	PrevHash  string            `json:"prevHash"`
+	Hash      string            `json:"hash"`
+}
+
+// digest returns the hash of the entry with the hash field cleared.
+func (e journalEntry) digest() (string, error) {
+	e.Hash = ""
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// journal is the append-only audit log in the data dir, shared by the daemon and the commands.
+type journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// detailString formats the detail and the error as key=value pairs.
+func (e journalEntry) detailString() string {
+	detail := make([]string, 0, len(e.Detail)+1)
+	for _, k := range slices.Sorted(maps.Keys(e.Detail)) {
+		detail = append(detail, k+"="+e.Detail[k])
+	}
+
+	if e.Error != "" {
+		detail = append(detail, "error="+e.Error)
+	}
+
+	return strings.Join(detail, " ")
+}
+
+func openJournal(dataDir string) *journal {
+	return &journal{
+		path: filepath.Join(dataDir, journalJsonl),
+	}
+}
+
+// record appends the entry, errors are only logged since the journal must never block the lifecycle.
+func (j *journal) record(e journalEntry) {
+	if j == nil {
+		return
+	}
+
+	if err := j.append(e); err != nil {
+		slog.Error("write journal failed", "type", e.Type, "err", err)
+	}
+}
+
+func (j *journal) append(e journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	// other processes, e.g. the approve command, append to the same journal
+	if err := lockFile(f); err != nil {
+		return err
+	}
+
+	defer unlockFile(f)
+
+	last, err := lastJournalEntry(f)
+	if err != nil {
+		return err
+	}
+
+	e.Seq = 1
+	if last != nil {
+		e.Seq = last.Seq + 1
+		e.PrevHash = last.Hash
+	}
+
+	e.Time = time.Now().UTC()
+
+	if e.Hash, err = e.digest(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+
+	return err
+}
+
+func lastJournalEntry(f *os.File) (*journalEntry, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if size == 0 {
+		return nil, nil
+	}
+
+	offset := max(size-journalTailSize, 0)
+
+	buf := make([]byte, size-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+
+	var e journalEntry
+	if err := json.Unmarshal(buf, &e); err != nil {
+		return nil, fmt.Errorf("parse last journal entry: %w", err)
+	}
+
+	return &e, nil
+}
+
+// entries reads all the entries of the journal.
+func (j *journal) entries() ([]journalEntry, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var entries []journalEntry
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// verifyJournal checks the hash chain, it returns the error of the first broken entry.
+func verifyJournal(entries []journalEntry) error {
+	prev := ""
+
+	for i, e := range entries {
+		if e.Seq != int64(i+1) {
+			return fmt.Errorf("entry %d: seq %d out of order", i+1, e.Seq)
+		}
+
+		if e.PrevHash != prev {
+			return fmt.Errorf("entry %d: previous hash mismatch", e.Seq)
+		}
+
+		hash, err := e.digest()
+		if err != nil {
+			return err
+		}
+
+		if hash != e.Hash {
+			return fmt.Errorf("entry %d: hash mismatch", e.Seq)
+		}
+
+		prev = e.Hash
+	}
+
+	return nil
+}
+
+// recordConfig records the settings when they differ from the last recorded ones.
+func (j *journal) recordConfig(settings map[string]string) {
+	entries, err := j.entries()
+	if err != nil {
+		slog.Error("read journal failed", "err", err)
+		return
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Type != journalConfigChanged {
+			continue
+		}
+
+		if maps.Equal(entries[i].Detail, settings) {
+			return
+		}
+
+		break
+	}
+
+	j.record(journalEntry{Type: journalConfigChanged, Detail: settings})
+}
+
+// configSettings returns the settings recorded in the journal, secrets like passwords and notify urls are left out.
+func configSettings(c *cli.Command) map[string]string {
+	settings := map[string]string{
+		flgFnosAddress:      c.String(flgFnosAddress),
+		flgFnosUsername:     c.String(flgFnosUsername),
+		flgDomains:          strings.Join(c.StringSlice(flgDomains), ","),
+		flgEmail:            c.String(flgEmail),
+		flgDnsProvider:      c.String(flgDnsProvider),
+		flgDnsResolvers:     strings.Join(c.StringSlice(flgDnsResolvers), ","),
+		flgCheckInterval:    c.Duration(flgCheckInterval).String(),
+		flgRenewDays:        strconv.FormatInt(c.Int(flgRenewDays), 10),
+		flgPreIssueHook:     c.String(flgPreIssueHook),
+		flgPostIssueHook:    c.String(flgPostIssueHook),
+		flgPostDeployHook:   c.String(flgPostDeployHook),
+		flgReplaceWindows:   strings.Join(c.StringSlice(flgReplaceWindows), ","),
+		flgRequireApproval:  strconv.FormatBool(c.Bool(flgRequireApproval)),
+		flgApprovalDeadline: c.Duration(flgApprovalDeadline).String(),
+		flgImportDir:        c.String(flgImportDir),
+		flgMQTTBroker:       c.String(flgMQTTBroker),
+		flgAdminListen:      c.String(flgAdminListen),
+	}
+
+	schemes := make([]string, 0, len(c.StringSlice(flgNotify)))
+	for _, rawURL := range c.StringSlice(flgNotify) {
+		if u, err := url.Parse(rawURL); err == nil {
+			schemes = append(schemes, u.Scheme)
+		}
+	}
+
+	settings[flgNotify] = strings.Join(schemes, ",")
+
+	return settings
+}
+
+// journalFilter selects journal entries, empty fields match all entries.
+type journalFilter struct {
+	Group string
+	NAS   string
+	// Type matches the type or the type prefix, e.g. nas matches nas.upload
+	Type  string
+	Since time.Time
+	Until time.Time
+}
+
+func (f journalFilter) match(e journalEntry) bool {
+	switch {
+	case f.Group != "" && e.Group != f.Group:
+		return false
+	case f.NAS != "" && e.NAS != f.NAS:
+		return false
+	case f.Type != "" && e.Type != f.Type && !strings.HasPrefix(e.Type, f.Type+"."):
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+
+	return true
+}
+
+func (f journalFilter) apply(entries []journalEntry) []journalEntry {
+	return slices.DeleteFunc(entries, func(e journalEntry) bool { return !f.match(e) })
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable, appends are only serialized in process.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) {}
@@ -9,10 +9,12 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
@@ -32,10 +34,44 @@ type cert struct {
 	rawCert []byte
 	rawKey  []byte
 	name    string
+	dir     string
+}
+
+type pendingCert struct {
+	cert
+	issuedAt time.Time
 }
 
 func listCertificates(dataDir string) ([]cert, error) {
-	matches, err := filepath.Glob(filepath.Join(dataDir, "certificates", "*.crt"))
+	return readCertificates(filepath.Join(dataDir, "certificates"))
+}
+
+func importedDir(dataDir string) string {
+	return filepath.Join(dataDir, "imported")
+}
+
+// listImportedCertificates returns the certificates imported from the drop folder.
+func listImportedCertificates(dataDir string) ([]cert, error) {
+	return readCertificates(importedDir(dataDir))
+}
+
+// listManagedCertificates returns both acme and imported certificates.
+func listManagedCertificates(dataDir string) ([]cert, error) {
+	certs, err := listCertificates(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := listImportedCertificates(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(certs, imported...), nil
+}
+
+func readCertificates(certDir string) ([]cert, error) {
+	matches, err := filepath.Glob(filepath.Join(certDir, "*.crt"))
 	if err != nil {
 		return nil, err
 	}
@@ -77,15 +113,51 @@ func listCertificates(dataDir string) ([]cert, error) {
 			rawCert:     data,
 			rawKey:      keyData,
 			name:        name,
+			dir:         certDir,
 		})
 	}
 
 	return certs, nil
 }
 
+// encodeCertificates encodes the chain as PEM, other blocks of the source, e.g. a private key, are left out.
+func encodeCertificates(chain []*x509.Certificate) []byte {
+	var data []byte
+	for _, c := range chain {
+		data = append(data, certcrypto.PEMEncode(certcrypto.DERCertificateBytes(c.Raw))...)
+	}
+
+	return data
+}
+
+// saveCertificate writes the certificate into a staging dir first, the current one is archived and
+// replaced by renaming only after all files have been written, so a failed write keeps the current one.
 func saveCertificate(dataDir string, cert *certificate.Resource) error {
-	certDir := filepath.Join(dataDir, "certificates")
+	stagingDir := filepath.Join(dataDir, "staging")
+
+	// leftovers of a failed save must not be moved in with the new certificate
+	if err := removeCertificate(stagingDir, cert.Domain); err != nil {
+		return err
+	}
+
+	if err := writeCertificate(stagingDir, cert); err != nil {
+		return err
+	}
+
+	if err := archiveCertificate(dataDir, cert.Domain); err != nil {
+		return err
+	}
+
+	if err := moveCertificate(stagingDir, filepath.Join(dataDir, "certificates"), cert.Domain); err != nil {
+		return err
+	}
+
+	slog.Info("saved certificate", "group", cert.Domain)
 
+	return nil
+}
+
+func writeCertificate(certDir string, cert *certificate.Resource) error {
 	if _, err := os.Stat(certDir); errors.Is(err, os.ErrNotExist) {
 		if err := os.MkdirAll(certDir, 0755); err != nil {
 			return err
@@ -115,11 +187,181 @@ func saveCertificate(dataDir string, cert *certificate.Resource) error {
 		return err
 	}
 
-	if err = os.WriteFile(filepath.Join(certDir, domain+resourceExt), jsonBytes, 0600); err != nil {
+	return os.WriteFile(filepath.Join(certDir, domain+resourceExt), jsonBytes, 0600)
+}
+
+// saveImportedCertificate stores the certificate imported from the drop folder.
+func saveImportedCertificate(dataDir string, cert *certificate.Resource) error {
+	if err := writeCertificate(importedDir(dataDir), cert); err != nil {
+		return err
+	}
+
+	slog.Info("saved imported certificate", "group", cert.Domain)
+
+	return nil
+}
+
+// savePendingCertificate parks the certificate until approved.
+func savePendingCertificate(dataDir string, cert *certificate.Resource) error {
+	if err := writeCertificate(filepath.Join(dataDir, "pending"), cert); err != nil {
 		return err
 	}
 
-	slog.Info("saved certificate", "domain", cert.Domain)
+	slog.Info("saved pending certificate", "group", cert.Domain)
+
+	return nil
+}
+
+func listPendingCertificates(dataDir string) ([]pendingCert, error) {
+	certs, err := readCertificates(filepath.Join(dataDir, "pending"))
+	if err != nil {
+		return nil, err
+	}
+
+	pendings := make([]pendingCert, 0, len(certs))
+
+	for _, c := range certs {
+		fi, err := os.Stat(filepath.Join(dataDir, "pending", c.name+certExt))
+		if err != nil {
+			return nil, err
+		}
+
+		pendings = append(pendings, pendingCert{cert: c, issuedAt: fi.ModTime()})
+	}
+
+	return pendings, nil
+}
+
+func hasPendingCertificate(dataDir, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(dataDir, "pending", name+certExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// approveCertificate moves the pending certificate into the certificate store.
+func approveCertificate(dataDir, name string) error {
+	pending, err := hasPendingCertificate(dataDir, name)
+	if err != nil {
+		return err
+	}
+
+	if !pending {
+		return fmt.Errorf("no pending certificate for %s", name)
+	}
+
+	if err := archiveCertificate(dataDir, name); err != nil {
+		return err
+	}
+
+	if err := moveCertificate(filepath.Join(dataDir, "pending"), filepath.Join(dataDir, "certificates"), name); err != nil {
+		return err
+	}
+
+	slog.Info("approved certificate", "group", name)
+
+	return nil
+}
+
+func previousDir(dataDir string) string {
+	return filepath.Join(dataDir, "previous")
+}
+
+// archiveCertificate keeps the current certificate of the group before it is replaced, so it can be rolled back.
+func archiveCertificate(dataDir, name string) error {
+	_, err := os.Stat(filepath.Join(dataDir, "certificates", name+certExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return moveCertificate(filepath.Join(dataDir, "certificates"), previousDir(dataDir), name)
+}
+
+// hasPreviousCertificate reports whether the group has a certificate to roll back to.
+func hasPreviousCertificate(dataDir, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(previousDir(dataDir), name+certExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// readPreviousCertificate parses the certificate the group can be rolled back to.
+func readPreviousCertificate(dataDir, name string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(filepath.Join(previousDir(dataDir), name+certExt))
+	if err != nil {
+		return nil, err
+	}
+
+	return certcrypto.ParsePEMCertificate(data)
+}
+
+// rollbackCertificate swaps the current and the previous certificate of the group,
+// rolling back twice restores the current one.
+func rollbackCertificate(dataDir, name string) error {
+	previous, err := hasPreviousCertificate(dataDir, name)
+	if err != nil {
+		return err
+	}
+
+	if !previous {
+		return fmt.Errorf("no previous certificate for %s", name)
+	}
+
+	certDir := filepath.Join(dataDir, "certificates")
+	swapDir := filepath.Join(dataDir, "rollback")
+
+	if err := moveCertificate(certDir, swapDir, name); err != nil {
+		return err
+	}
+
+	if err := moveCertificate(previousDir(dataDir), certDir, name); err != nil {
+		return err
+	}
+
+	if err := moveCertificate(swapDir, previousDir(dataDir), name); err != nil {
+		return err
+	}
+
+	slog.Info("rolled back certificate", "group", name)
+
+	return os.Remove(swapDir)
+}
+
+// removeCertificate removes the files of the certificate in the dir.
+func removeCertificate(dir, name string) error {
+	for _, ext := range []string{certExt, issuerExt, keyExt, resourceExt} {
+		if err := os.Remove(filepath.Join(dir, name+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveCertificate moves the files of the certificate between dirs, replacing the files in the target dir.
+func moveCertificate(fromDir, toDir, name string) error {
+	if err := os.MkdirAll(toDir, 0755); err != nil {
+		return err
+	}
+
+	if err := removeCertificate(toDir, name); err != nil {
+		return err
+	}
+
+	for _, ext := range []string{certExt, issuerExt, keyExt, resourceExt} {
+		err := os.Rename(filepath.Join(fromDir, name+ext), filepath.Join(toDir, name+ext))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -7,29 +7,204 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/providers/dns"
 	"github.com/go-acme/lego/v4/registration"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	userAgent = "fnos-acme/0.1.0"
+	version   = "0.1.0"
+	userAgent = "fnos-acme/" + version
 )
 
-func setupDNSChallenge(client *lego.Client, providerName string, wait time.Duration, resolvers []string) error {
+// challengeObserver carries the journal and the trace context of the running order into the DNS provider,
+// since lego does not pass context to providers.
+type challengeObserver struct {
+	journal *journal
+
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// begin sets the context of the order, the returned function resets it.
+func (o *challengeObserver) begin(ctx context.Context) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.ctx = ctx
+
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		o.ctx = nil
+	}
+}
+
+func (o *challengeObserver) context() context.Context {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.ctx == nil {
+		return context.Background()
+	}
+
+	return o.ctx
+}
+
+// observedChallenge is a challenge between presenting and cleaning up.
+type observedChallenge struct {
+	start time.Time
+	ctx   context.Context
+	span  trace.Span
+}
+
+// observedProvider records the duration from presenting to cleaning up each DNS-01 challenge.
+type observedProvider struct {
+	challenge.Provider
+	name     string
+	observer *challengeObserver
+	started  sync.Map
+}
+
+// sequentialObservedProvider keeps the Sequential behavior of the wrapped provider.
+type sequentialObservedProvider struct {
+	*observedProvider
+}
+
+func observeProvider(name string, provider challenge.Provider, observer *challengeObserver) *observedProvider {
+	return &observedProvider{
+		Provider: provider,
+		name:     name,
+		observer: observer,
+	}
+}
+
+func (p *observedProvider) withSequential() challenge.Provider {
+	if _, ok := p.Provider.(interface{ Sequential() time.Duration }); ok {
+		return sequentialObservedProvider{p}
+	}
+
+	return p
+}
+
+func (p *observedProvider) Present(domain, token, keyAuth string) error {
+	ctx, chSpan := tracer.Start(p.observer.context(), "dns.challenge", trace.WithAttributes(
+		attribute.String("dns.domain", domain),
+		attribute.String("dns.provider", p.name),
+	))
+
+	p.started.Store(domain+token, observedChallenge{start: time.Now(), ctx: ctx, span: chSpan})
+	p.started.Store(domain, observedChallenge{ctx: ctx})
+
+	ctx, span := tracer.Start(ctx, "dns.present")
+	err := p.Provider.Present(domain, token, keyAuth)
+	endSpan(span, err)
+
+	slog.DebugContext(ctx, "present dns challenge", "domain", domain, "provider", p.name, "err", err)
+
+	p.record(journalChallengePresent, domain, err)
+
+	return err
+}
+
+func (p *observedProvider) CleanUp(domain, token, keyAuth string) error {
+	ctx := p.observer.context()
+
+	v, ok := p.started.LoadAndDelete(domain + token)
+	if ok {
+		ctx = v.(observedChallenge).ctx
+		metricDNSChallengeDuration.WithLabelValues(p.name).Observe(time.Since(v.(observedChallenge).start).Seconds())
+	}
+
+	p.started.Delete(domain)
+
+	ctx, span := tracer.Start(ctx, "dns.cleanup")
+	err := p.Provider.CleanUp(domain, token, keyAuth)
+	endSpan(span, err)
+
+	slog.DebugContext(ctx, "clean up dns challenge", "domain", domain, "provider", p.name, "err", err)
+
+	if ok {
+		v.(observedChallenge).span.End()
+	}
+
+	p.record(journalChallengeCleanUp, domain, err)
+
+	return err
+}
+
+// preCheck waits for the propagation of the record, it sleeps the fixed wait or does the default check.
+func (p *observedProvider) preCheck(wait time.Duration) dns01.WrapPreCheckFunc {
+	return func(domain, fqdn, value string, check dns01.PreCheckFunc) (bool, error) {
+		ctx := p.observer.context()
+		if v, ok := p.started.Load(domain); ok {
+			ctx = v.(observedChallenge).ctx
+		}
+
+		_, span := tracer.Start(ctx, "dns.propagation", trace.WithAttributes(attribute.String("dns.fqdn", fqdn)))
+
+		if wait > 0 {
+			time.Sleep(wait)
+			span.End()
+
+			return true, nil
+		}
+
+		ok, err := check(fqdn, value)
+		span.SetAttributes(attribute.Bool("dns.propagated", ok))
+		endSpan(span, err)
+
+		return ok, err
+	}
+}
+
+func (p *observedProvider) Timeout() (timeout, interval time.Duration) {
+	if t, ok := p.Provider.(challenge.ProviderTimeout); ok {
+		return t.Timeout()
+	}
+
+	return dns01.DefaultPropagationTimeout, dns01.DefaultPollingInterval
+}
+
+func (p *observedProvider) record(typ, domain string, err error) {
+	e := journalEntry{
+		Type:   typ,
+		Detail: map[string]string{"domain": domain, "provider": p.name},
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	p.observer.journal.record(e)
+}
+
+func (p sequentialObservedProvider) Sequential() time.Duration {
+	return p.Provider.(interface{ Sequential() time.Duration }).Sequential()
+}
+
+func setupDNSChallenge(client *lego.Client, providerName string, wait time.Duration, resolvers []string, observer *challengeObserver) error {
 	provider, err := dns.NewDNSChallengeProviderByName(providerName)
 	if err != nil {
 		return err
 	}
 
-	return client.Challenge.SetDNS01Provider(provider,
+	observed := observeProvider(providerName, provider, observer)
+
+	return client.Challenge.SetDNS01Provider(observed.withSequential(),
 		dns01.CondOption(len(resolvers) > 0, dns01.AddRecursiveNameservers(dns01.ParseNameservers(resolvers))),
-		dns01.CondOption(wait > 0, dns01.PropagationWait(wait, true)),
+		dns01.WrapPreCheck(observed.preCheck(wait)),
 	)
 }
 
@@ -40,6 +215,7 @@ func newClient(
 	providerName string,
 	wait time.Duration,
 	resolvers []string,
+	observer *challengeObserver,
 ) (*lego.Client, error) {
 	config := lego.NewConfig(acc)
 	config.Certificate = lego.CertificateConfig{
@@ -55,7 +231,7 @@ func newClient(
 		return nil, err
 	}
 
-	if err := setupDNSChallenge(client, providerName, wait, resolvers); err != nil {
+	if err := setupDNSChallenge(client, providerName, wait, resolvers, observer); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	flgLogFormat         = "log-format"
+	flgLogLevel          = "log-level"
+	flgLogFile           = "log-file"
+	flgLogFileMaxSize    = "log-file-max-size"
+	flgLogFileMaxBackups = "log-file-max-backups"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+func loggingFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    flgLogFormat,
+			Value:   logFormatText,
+			Usage:   "log format, text or json",
+			Sources: cli.EnvVars("LOG_FORMAT"),
+		},
+		&cli.StringFlag{
+			Name:    flgLogLevel,
+			Value:   "info",
+			Usage:   "log level, one of debug, info, warn, error",
+			Sources: cli.EnvVars("LOG_LEVEL"),
+		},
+		&cli.StringFlag{
+			Name:    flgLogFile,
+			Usage:   "also write logs of the run command to the file, relative to the data dir",
+			Sources: cli.EnvVars("LOG_FILE"),
+		},
+		&cli.IntFlag{
+			Name:    flgLogFileMaxSize,
+			Value:   10,
+			Usage:   "max size in MB of the log file before it is rotated",
+			Sources: cli.EnvVars("LOG_FILE_MAX_SIZE"),
+		},
+		&cli.IntFlag{
+			Name:    flgLogFileMaxBackups,
+			Value:   5,
+			Usage:   "max number of rotated log files to keep",
+			Sources: cli.EnvVars("LOG_FILE_MAX_BACKUPS"),
+		},
+	}
+}
+
+// setupLogging installs the default logger, logs are also written to the log file if withFile is set.
+// The returned closer closes the log file.
+func setupLogging(c *cli.Command, withFile bool) (io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.String(flgLogLevel))); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", c.String(flgLogLevel))
+	}
+
+	if c.Bool(flgDebug) {
+		level = slog.LevelDebug
+	}
+
+	var (
+		w      io.Writer = os.Stderr
+		closer io.Closer = io.NopCloser(nil)
+	)
+
+	if name := logFilePath(c); withFile && name != "" {
+		f, err := openRotatingFile(name, c.Int(flgLogFileMaxSize)<<20, int(c.Int(flgLogFileMaxBackups)))
+		if err != nil {
+			return nil, err
+		}
+
+		w = io.MultiWriter(os.Stderr, f)
+		closer = f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+
+	switch strings.ToLower(c.String(flgLogFormat)) {
+	case logFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("invalid log format %q", c.String(flgLogFormat))
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+
+	return closer, nil
+}
+
+// logFilePath returns the log file, relative names are in the data dir.
+func logFilePath(c *cli.Command) string {
+	name := c.String(flgLogFile)
+	if name != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(c.String(flgDataDir), name)
+	}
+
+	return name
+}
+
+type logAttrsKey struct{}
+
+// withLogAttrs returns a context whose log lines carry the attributes, e.g. the group and the nas.
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+
+	merged := slices.DeleteFunc(slices.Clone(parent), func(a slog.Attr) bool {
+		return slices.ContainsFunc(attrs, func(b slog.Attr) bool { return a.Key == b.Key })
+	})
+
+	return context.WithValue(ctx, logAttrsKey{}, append(merged, attrs...))
+}
+
+// contextHandler adds the attributes and the trace and span id of the context to log records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// rotatingFile is a log file rotated by size, the backups are named <name>.1 to <name>.<maxBackups>.
+type rotatingFile struct {
+	name       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(name string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	r := &rotatingFile{
+		name:       name,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.f = f
+	r.size = info.Size()
+
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+
+	err := r.shift()
+
+	// keep logging to the file even if the backups can not be shifted
+	if err := r.open(); err != nil {
+		r.f = nil
+		return err
+	}
+
+	return err
+}
+
+// shift renames <name> to <name>.1, <name>.1 to <name>.2 and so on, the oldest backup is overwritten.
+func (r *rotatingFile) shift() error {
+	if r.maxBackups <= 0 {
+		return os.Remove(r.name)
+	}
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", r.name, i), fmt.Sprintf("%s.%d", r.name, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return os.Rename(r.name, r.name+".1")
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// the failure of shifting backups is ignored as long as the file is reopened
+		if err := r.rotate(); err != nil && r.f == nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+
+	return err
+}
@@ -7,6 +7,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 
@@ -23,15 +24,74 @@ const (
 	flgDnsProvider  = "dns-provider"
 	flgDnsResolvers = "dns-resolvers"
 	flgDebug        = "debug"
+	flgDumpProtocol = "dump-protocol"
 )
 
 func main() {
-	root := &cli.Command{
+	var logFile io.Closer
+
+	root := newRootCommand()
+	root.Before = func(ctx context.Context, c *cli.Command) (context.Context, error) {
+		ctx, err := applyConfigFile(ctx, c)
+		if err != nil {
+			return ctx, err
+		}
+
+		_, err = setupLogging(c, false)
+
+		return ctx, err
+	}
+
+	// only the daemon writes the log file, other commands log to stderr
+	run := root.Command("run")
+	run.Before = func(ctx context.Context, c *cli.Command) (context.Context, error) {
+		ctx, err := applyConfigFile(ctx, c)
+		if err != nil {
+			return ctx, err
+		}
+
+		logFile, err = setupLogging(c, true)
+
+		return ctx, err
+	}
+	run.After = func(ctx context.Context, c *cli.Command) error {
+		if logFile != nil {
+			return logFile.Close()
+		}
+
+		return nil
+	}
+
+	if err := root.Run(context.Background(), os.Args); err != nil {
+		slog.Error("run failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cli.Command {
+	return &cli.Command{
 		Name: "fnos-acme",
 		Commands: []*cli.Command{
 			commandRun(),
+			commandPending(),
+			commandApprove(),
+			commandHealthcheck(),
+			commandHistory(),
+			commandCtl(),
+			commandTUI(),
+			commandStatus(),
+			commandInspect(),
+			commandNAS(),
+			commandRPC(),
+			commandInit(),
 		},
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    flgConfig,
+				Value:   "",
+				Usage:   "config file of KEY=VALUE lines named as the environment variables, re-read by run on SIGHUP",
+				Sources: cli.EnvVars("CONFIG"),
+			},
 			&cli.StringFlag{
 				Name:    flgDataDir,
 				Value:   "/app/fnos-acme",
@@ -86,11 +146,12 @@ func main() {
 				Usage:   "debug mode",
 				Sources: cli.EnvVars("DEBUG"),
 			},
-		},
-	}
-
-	if err := root.Run(context.Background(), os.Args); err != nil {
-		slog.Error("run failed", "err", err)
-		os.Exit(1)
+			&cli.BoolFlag{
+				Name:    flgDumpProtocol,
+				Value:   false,
+				Usage:   "log fnos protocol payloads unredacted at debug level, they contain passwords and private keys",
+				Sources: cli.EnvVars("DUMP_PROTOCOL"),
+			},
+		}, loggingFlags()...),
 	}
 }
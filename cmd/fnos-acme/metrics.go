@@ -0,0 +1,126 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/trim/rpc"
+	rpcerrors "github.com/cospotato/fnos-acme/internal/trim/rpc/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	metricsNamespace = "fnos_acme"
+)
+
+var (
+	metricCertNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "certificate_not_after_timestamp_seconds",
+		Help:      "Expiry of the local certificate.",
+	}, []string{"group"})
+
+	metricNASCertNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "nas_certificate_not_after_timestamp_seconds",
+		Help:      "Expiry of the certificate deployed in fnos.",
+	}, []string{"group", "nas"})
+
+	metricLastCheck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_check_success_timestamp_seconds",
+		Help:      "Time of the last successful certificate check.",
+	})
+
+	metricLastIssue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_issue_success_timestamp_seconds",
+		Help:      "Time of the last successful certificate issuance.",
+	}, []string{"group"})
+
+	metricLastDeploy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_deploy_success_timestamp_seconds",
+		Help:      "Time of the last successful certificate deployment to fnos.",
+	}, []string{"group", "nas"})
+
+	metricACMEOrders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "acme_orders_total",
+		Help:      "ACME orders by outcome.",
+	}, []string{"group", "outcome"})
+
+	metricDNSChallengeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "dns_challenge_duration_seconds",
+		Help:      "Duration from presenting the DNS-01 record to cleaning it up.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 8),
+	}, []string{"provider"})
+
+	metricRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of fnos rpc calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	metricRPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_errors_total",
+		Help:      "Failed fnos rpc calls by errno.",
+	}, []string{"method", "errno"})
+
+	metricReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "nas_reconnects_total",
+		Help:      "Websocket reconnections to fnos by outcome.",
+	}, []string{"nas", "outcome"})
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metricCertNotAfter,
+		metricNASCertNotAfter,
+		metricLastCheck,
+		metricLastIssue,
+		metricLastDeploy,
+		metricACMEOrders,
+		metricDNSChallengeDuration,
+		metricRPCDuration,
+		metricRPCErrors,
+		metricReconnects,
+	)
+}
+
+func outcomeOf(err error) string {
+	if err != nil {
+		return outcomeFailure
+	}
+
+	return outcomeSuccess
+}
+
+func metricsInterceptor(ctx context.Context, method string, req, reply any, cc *rpc.ClientConn, invoker rpc.UnaryInvoker, opts ...rpc.CallOption) error {
+	start := time.Now()
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	metricRPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metricRPCErrors.WithLabelValues(method, strconv.FormatUint(uint64(rpcerrors.Code(err)), 10)).Inc()
+	}
+
+	return err
+}
@@ -0,0 +1,296 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	mqttQoS           = 1
+	mqttPayloadOnline = "online"
+	mqttPayloadOff    = "offline"
+	mqttPayloadRenew  = "PRESS"
+)
+
+// mqttState is the state of a certificate published to <prefix>/<id>/state.
+type mqttState struct {
+	Group         string    `json:"group"`
+	Domains       []string  `json:"domains"`
+	Source        string    `json:"source"`
+	DaysRemaining int       `json:"days_remaining"`
+	NotAfter      time.Time `json:"not_after"`
+	LastRenewal   time.Time `json:"last_renewal"`
+	LastError     string    `json:"last_error"`
+	NASStatus     string    `json:"nas_status"`
+	NASCertID     int       `json:"nas_cert_id"`
+}
+
+// mqttPublisher publishes certificate states with Home Assistant discovery,
+// and receives renew commands from <prefix>/<id>/renew.
+type mqttPublisher struct {
+	client          mqtt.Client
+	prefix          string
+	discoveryPrefix string
+	renew           chan string
+	refresh         chan struct{}
+
+	mu        sync.Mutex
+	announced map[string]bool
+}
+
+// newMQTTPublisher connects the broker, returns nil if no broker is configured.
+func newMQTTPublisher(c *cli.Command) (*mqttPublisher, error) {
+	if c.String(flgMQTTBroker) == "" {
+		return nil, nil
+	}
+
+	p := &mqttPublisher{
+		prefix:          strings.TrimSuffix(c.String(flgMQTTTopicPrefix), "/"),
+		discoveryPrefix: strings.TrimSuffix(c.String(flgMQTTDiscoveryPrefix), "/"),
+		renew:           make(chan string, 8),
+		refresh:         make(chan struct{}, 1),
+		announced:       make(map[string]bool),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(c.String(flgMQTTBroker)).
+		SetClientID(c.String(flgMQTTClientID)).
+		SetUsername(c.String(flgMQTTUsername)).
+		SetPassword(c.String(flgMQTTPassword)).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWill(p.availabilityTopic(), mqttPayloadOff, mqttQoS, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Error("mqtt connection lost", "err", err)
+		})
+
+	p.client = mqtt.NewClient(opts)
+
+	// with connect retry the token only completes once connected, so do not wait forever
+	token := p.client.Connect()
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return p, nil
+}
+
+func (p *mqttPublisher) availabilityTopic() string {
+	return p.prefix + "/status"
+}
+
+func (p *mqttPublisher) stateTopic(id string) string {
+	return p.prefix + "/" + id + "/state"
+}
+
+func (p *mqttPublisher) renewTopic(id string) string {
+	return p.prefix + "/" + id + "/renew"
+}
+
+func (p *mqttPublisher) onConnect(client mqtt.Client) {
+	slog.Info("mqtt connected")
+
+	p.mu.Lock()
+	clear(p.announced)
+	p.mu.Unlock()
+
+	client.Publish(p.availabilityTopic(), mqttQoS, true, mqttPayloadOnline)
+
+	client.Subscribe(p.renewTopic("+"), mqttQoS, func(_ mqtt.Client, msg mqtt.Message) {
+		id := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), p.prefix+"/"), "/renew")
+
+		select {
+		case p.renew <- id:
+		default:
+			slog.Warn("too many renew requests, drop it", "id", id)
+		}
+	})
+
+	select {
+	case p.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Renew returns the ids of certificates requested to renew.
+func (p *mqttPublisher) Renew() <-chan string {
+	if p == nil {
+		return nil
+	}
+
+	return p.renew
+}
+
+// Refresh is signaled on (re)connection, when all states should be published again.
+func (p *mqttPublisher) Refresh() <-chan struct{} {
+	if p == nil {
+		return nil
+	}
+
+	return p.refresh
+}
+
+// mqttID converts the group to a topic level and entity id, e.g. *.example.com to __example_com.
+func mqttID(group string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, group)
+}
+
+func (p *mqttPublisher) publishJSON(topic string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("marshal mqtt payload failed", "topic", topic, "err", err)
+		return
+	}
+
+	p.client.Publish(topic, mqttQoS, true, data)
+}
+
+// announce publishes the Home Assistant discovery configs of the certificate once per connection.
+func (p *mqttPublisher) announce(id string, s mqttState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.announced[id] {
+		return
+	}
+
+	device := map[string]any{
+		"identifiers":  []string{"fnos_acme_" + id},
+		"name":         "Certificate " + s.Group,
+		"manufacturer": "fnos-acme",
+		"model":        s.Source,
+	}
+
+	entity := func(component, key, name string, extra map[string]any) {
+		config := map[string]any{
+			"name":               name,
+			"unique_id":          "fnos_acme_" + id + "_" + key,
+			"object_id":          "fnos_acme_" + id + "_" + key,
+			"availability_topic": p.availabilityTopic(),
+			"device":             device,
+		}
+
+		if component != "button" {
+			config["state_topic"] = p.stateTopic(id)
+			config["value_template"] = fmt.Sprintf("{{ value_json.%s }}", key)
+		}
+
+		for k, v := range extra {
+			config[k] = v
+		}
+
+		p.publishJSON(fmt.Sprintf("%s/%s/fnos_acme_%s/%s/config", p.discoveryPrefix, component, id, key), config)
+	}
+
+	entity("sensor", "days_remaining", "Days remaining", map[string]any{
+		"unit_of_measurement": "d",
+		"state_class":         "measurement",
+		"icon":                "mdi:certificate",
+	})
+	entity("sensor", "not_after", "Expires", map[string]any{"device_class": "timestamp"})
+	entity("sensor", "last_renewal", "Last renewal", map[string]any{"device_class": "timestamp"})
+	entity("sensor", "last_error", "Last error", map[string]any{"icon": "mdi:alert-circle"})
+	entity("sensor", "nas_status", "NAS status", map[string]any{"icon": "mdi:nas"})
+
+	if s.Source == "acme" {
+		entity("button", "renew", "Renew now", map[string]any{
+			"command_topic": p.renewTopic(id),
+			"payload_press": mqttPayloadRenew,
+			"icon":          "mdi:refresh",
+		})
+	}
+
+	p.announced[id] = true
+}
+
+// publish publishes the states of the certificates in the digest.
+func (p *mqttPublisher) publish(d notify.Digest) {
+	if p == nil || !p.client.IsConnectionOpen() {
+		return
+	}
+
+	for _, c := range d.Certificates {
+		s := mqttState{
+			Group:         c.Group,
+			Domains:       c.Domains,
+			Source:        c.Source,
+			DaysRemaining: c.DaysLeft(),
+			NotAfter:      c.NotAfter,
+			LastRenewal:   c.NotBefore,
+			LastError:     c.LastError,
+			NASStatus:     c.NASState,
+			NASCertID:     c.NASCert,
+		}
+
+		// the pending deployment error is preferred, or the latest failure of the group
+		for i := len(d.Errors) - 1; i >= 0 && s.LastError == ""; i-- {
+			if d.Errors[i].Group == c.Group {
+				s.LastError = d.Errors[i].Error
+			}
+		}
+
+		id := mqttID(c.Group)
+		p.announce(id, s)
+		p.publishJSON(p.stateTopic(id), s)
+	}
+}
+
+func (p *mqttPublisher) Close() {
+	if p == nil {
+		return
+	}
+
+	p.client.Publish(p.availabilityTopic(), mqttQoS, true, mqttPayloadOff).WaitTimeout(time.Second)
+	p.client.Disconnect(250)
+}
+
+// publishState publishes the certificate states to mqtt if configured.
+func (u *updater) publishState(ctx context.Context) {
+	if u.mqtt == nil {
+		return
+	}
+
+	d, err := u.buildDigest(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "collect certificate states failed", "err", err)
+		return
+	}
+
+	u.mqtt.publish(d)
+}
+
+// renewNow obtains a new certificate for the renew command, only acme certificates can be renewed.
+func (u *updater) renewNow(ctx context.Context, id string) error {
+	if id != mqttID(u.domains[0]) {
+		return fmt.Errorf("certificate %s is not managed by acme", id)
+	}
+
+	ctx = withLogAttrs(ctx, slog.String("group", u.domains[0]))
+
+	slog.InfoContext(ctx, "renew certificate on request")
+
+	_, err := u.obtainIfNotPending(ctx)
+
+	return err
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/urfave/cli/v3"
+)
+
+// testBroker is an embedded mqtt broker which records the last payload of every topic.
+type testBroker struct {
+	*mqttserver.Server
+	address string
+
+	mu       sync.Mutex
+	messages map[string][]byte
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	server := mqttserver.New(&mqttserver.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBroker{Server: server, address: "tcp://" + tcp.Address(), messages: make(map[string][]byte)}
+
+	err := server.Subscribe("#", 1, func(_ *mqttserver.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.messages[pk.TopicName] = pk.Payload
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	return b
+}
+
+// wait returns the payload of the topic once it is published.
+func (b *testBroker) wait(t *testing.T, topic string) []byte {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		payload, ok := b.messages[topic]
+		b.mu.Unlock()
+
+		if ok {
+			return payload
+		}
+	}
+
+	t.Fatalf("topic %s is not published", topic)
+	return nil
+}
+
+// has reports whether the topic has been published.
+func (b *testBroker) has(topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.messages[topic]
+	return ok
+}
+
+// newTestPublisher connects the broker with the flags of the run command.
+func newTestPublisher(t *testing.T, b *testBroker) *mqttPublisher {
+	t.Helper()
+
+	var p *mqttPublisher
+
+	cmd := commandRun()
+	cmd.Before = nil
+	cmd.Action = func(_ context.Context, c *cli.Command) (err error) {
+		p, err = newMQTTPublisher(c)
+		return err
+	}
+
+	if err := cmd.Run(context.Background(), []string{"run", "--" + flgMQTTBroker, b.address}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	b.wait(t, "fnos-acme/status")
+
+	return p
+}
+
+func TestMQTTPublish(t *testing.T) {
+	b := newTestBroker(t)
+	p := newTestPublisher(t, b)
+
+	notAfter := time.Now().Add(30*24*time.Hour + time.Hour).UTC().Truncate(time.Second)
+
+	p.publish(notify.Digest{
+		Certificates: []notify.DigestCert{
+			{
+				Group:     "*.example.com",
+				Domains:   []string{"*.example.com", "example.com"},
+				Source:    "acme",
+				NotBefore: notAfter.Add(-90 * 24 * time.Hour),
+				NotAfter:  notAfter,
+				NASState:  nasStateMissing,
+			},
+			{
+				Group:    "imported.example.org",
+				Domains:  []string{"imported.example.org"},
+				Source:   "import",
+				NotAfter: notAfter,
+				NASState: "deployed",
+				NASCert:  3,
+			},
+		},
+		Errors: []notify.Event{
+			{Type: notify.EventFailed, Group: "*.example.com", Error: "old"},
+			{Type: notify.EventFailed, Group: "*.example.com", Error: "rate limited"},
+		},
+	})
+
+	if status := b.wait(t, "fnos-acme/status"); string(status) != mqttPayloadOnline {
+		t.Fatalf("status = %s", status)
+	}
+
+	var state mqttState
+	if err := json.Unmarshal(b.wait(t, "fnos-acme/__example_com/state"), &state); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Group != "*.example.com" || state.Source != "acme" || state.DaysRemaining != 30 ||
+		!state.NotAfter.Equal(notAfter) || state.NASStatus != nasStateMissing || state.LastError != "rate limited" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	var config map[string]any
+	if err := json.Unmarshal(b.wait(t, "homeassistant/sensor/fnos_acme___example_com/days_remaining/config"), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config["state_topic"] != "fnos-acme/__example_com/state" || config["availability_topic"] != "fnos-acme/status" ||
+		config["value_template"] != "{{ value_json.days_remaining }}" || config["unique_id"] != "fnos_acme___example_com_days_remaining" {
+		t.Fatalf("days_remaining config = %v", config)
+	}
+
+	var button map[string]any
+	if err := json.Unmarshal(b.wait(t, "homeassistant/button/fnos_acme___example_com/renew/config"), &button); err != nil {
+		t.Fatal(err)
+	}
+
+	if button["command_topic"] != "fnos-acme/__example_com/renew" || button["payload_press"] != mqttPayloadRenew {
+		t.Fatalf("renew config = %v", button)
+	}
+
+	if err := json.Unmarshal(b.wait(t, "fnos-acme/imported_example_org/state"), &state); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Source != "import" || state.NASCertID != 3 || state.LastError != "" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	b.wait(t, "homeassistant/sensor/fnos_acme_imported_example_org/nas_status/config")
+	if b.has("homeassistant/button/fnos_acme_imported_example_org/renew/config") {
+		t.Fatal("renew button announced for an imported certificate")
+	}
+}
+
+func TestMQTTRenewCommand(t *testing.T) {
+	b := newTestBroker(t)
+	p := newTestPublisher(t, b)
+
+	// the subscription is made on connect, wait until the broker has it
+	for deadline := time.Now().Add(5 * time.Second); len(b.Topics.Subscribers("fnos-acme/__example_com/renew").Subscriptions) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("renew topic is not subscribed")
+		}
+	}
+
+	if err := b.Publish("fnos-acme/__example_com/renew", []byte(mqttPayloadRenew), false, mqttQoS); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-p.Renew():
+		if id != mqttID("*.example.com") {
+			t.Fatalf("renew id = %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("renew command is not received")
+	}
+}
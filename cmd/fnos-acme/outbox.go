@@ -0,0 +1,186 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	outboxJson = "outbox.json"
+)
+
+const (
+	outboxMinBackoff = time.Minute
+	outboxMaxBackoff = time.Hour
+)
+
+// outboxOp is a pending fnos deployment which failed and will be retried.
+type outboxOp struct {
+	Group       string    `json:"group"`
+	Edit        bool      `json:"edit"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// outbox persists the pending fnos deployments in the data dir, so they survive restarts.
+type outbox struct {
+	mu   sync.Mutex
+	path string
+	ops  map[string]*outboxOp
+}
+
+func loadOutbox(dataDir string) (*outbox, error) {
+	o := &outbox{
+		path: filepath.Join(dataDir, outboxJson),
+		ops:  make(map[string]*outboxOp),
+	}
+
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return o, nil
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &o.ops); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *outbox) save() error {
+	data, err := json.MarshalIndent(o.ops, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, o.path)
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxMinBackoff
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, outboxMaxBackoff)
+}
+
+// add records a failed deployment of the group, or bumps the attempts if already queued.
+func (o *outbox) add(group string, edit bool, cause error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	op, ok := o.ops[group]
+	if !ok {
+		op = &outboxOp{
+			Group:     group,
+			CreatedAt: time.Now(),
+		}
+		o.ops[group] = op
+	}
+
+	op.Edit = op.Edit || edit
+	op.Attempts++
+	op.NextAttempt = time.Now().Add(outboxBackoff(op.Attempts))
+	op.LastError = cause.Error()
+
+	return o.save()
+}
+
+// postpone queues the deployment of the group to the maintenance window opening at until, the replacement
+// has been deferred rather than failed so the attempts are kept.
+func (o *outbox) postpone(group string, edit bool, until time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	op, ok := o.ops[group]
+	if !ok {
+		op = &outboxOp{
+			Group:     group,
+			CreatedAt: time.Now(),
+		}
+		o.ops[group] = op
+	}
+
+	op.Edit = op.Edit || edit
+	op.NextAttempt = until
+
+	return o.save()
+}
+
+func (o *outbox) remove(group string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.ops[group]; !ok {
+		return nil
+	}
+
+	delete(o.ops, group)
+
+	return o.save()
+}
+
+func (o *outbox) get(group string) (outboxOp, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	op, ok := o.ops[group]
+	if !ok {
+		return outboxOp{}, false
+	}
+
+	return *op, true
+}
+
+// due returns the operations to retry at now, or all of them if force is set.
+func (o *outbox) due(now time.Time, force bool) []outboxOp {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ops := make([]outboxOp, 0, len(o.ops))
+
+	for _, op := range o.ops {
+		if force || !op.NextAttempt.After(now) {
+			ops = append(ops, *op)
+		}
+	}
+
+	return ops
+}
+
+// nextAttempt returns the earliest retry time, or zero time if the outbox is empty.
+func (o *outbox) nextAttempt() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var next time.Time
+
+	for _, op := range o.ops {
+		if next.IsZero() || op.NextAttempt.Before(next) {
+			next = op.NextAttempt
+		}
+	}
+
+	return next
+}
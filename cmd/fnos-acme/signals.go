@@ -0,0 +1,224 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cospotato/fnos-acme/internal/notify"
+	"github.com/cospotato/fnos-acme/internal/trim"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flgShutdownTimeout = "shutdown-timeout"
+)
+
+// restartSettings are the flags which are not applied by reload.
+var restartSettings = []string{
+	flgDataDir,
+	flgListen,
+	flgAdminListen,
+	flgAdminToken,
+	flgAdminTLSCert,
+	flgAdminTLSKey,
+	flgImportDir,
+	flgMQTTBroker,
+	flgMQTTUsername,
+	flgMQTTPassword,
+	flgMQTTClientID,
+	flgMQTTTopicPrefix,
+	flgMQTTDiscoveryPrefix,
+	flgTraceExporter,
+	flgTraceEndpoint,
+	flgTraceSampleRatio,
+	flgLogFormat,
+	flgLogLevel,
+	flgLogFile,
+	flgDebug,
+}
+
+// watchShutdown waits for SIGINT or SIGTERM, the returned channel is closed to stop the run loop after the
+// in-flight step. If the step does not finish in time or the signal is received again, ctx is canceled to abort it.
+func watchShutdown(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stopping := make(chan struct{})
+
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case sig := <-signals:
+			slog.InfoContext(ctx, "shutting down, wait for the in-flight step", "signal", sig, "timeout", timeout)
+		case <-ctx.Done():
+			return
+		}
+
+		close(stopping)
+
+		select {
+		case sig := <-signals:
+			slog.WarnContext(ctx, "abort the in-flight step", "signal", sig)
+		case <-time.After(timeout):
+			slog.WarnContext(ctx, "shutdown timeout, abort the in-flight step")
+		case <-ctx.Done():
+			return
+		}
+
+		cancel()
+	}()
+
+	return stopping
+}
+
+// watchHangup delivers SIGHUP to reload the config.
+func watchHangup() (<-chan os.Signal, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	return signals, func() { signal.Stop(signals) }
+}
+
+// reparseCommand parses the command line again with the current config file and environment,
+// it returns the run command without running it.
+func reparseCommand(ctx context.Context) (*cli.Command, error) {
+	var parsed *cli.Command
+
+	root := newRootCommand()
+	root.Before = applyConfigFile
+	root.Writer, root.ErrWriter = io.Discard, io.Discard
+
+	for _, cmd := range root.Commands {
+		if cmd.Name == "run" {
+			cmd.Action = func(_ context.Context, c *cli.Command) error {
+				parsed = c
+				return nil
+			}
+		}
+	}
+
+	if err := root.Run(ctx, os.Args); err != nil {
+		return nil, err
+	}
+
+	if parsed == nil {
+		return nil, errors.New("run command not found in arguments")
+	}
+
+	return parsed, nil
+}
+
+func settingsChanged(prev, next *cli.Command, names ...string) bool {
+	for _, name := range names {
+		if fmt.Sprint(prev.Value(name)) != fmt.Sprint(next.Value(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reload applies the settings of the next command, the fnos, notify and acme clients are rebuilt only if
+// their settings changed. Nothing is applied if any of the settings is invalid, the caller restores the
+// environment exported from the config file then.
+func (u *updater) reload(ctx context.Context, prev, next *cli.Command,
+	connect func(*cli.Command) (*trim.Client, error),
+	acme func(*cli.Command) (*lego.Client, error),
+) (err error) {
+	if err := flagCheck(next); err != nil {
+		return err
+	}
+
+	windows, err := parseMaintenanceWindows(next.StringSlice(flgReplaceWindows))
+	if err != nil {
+		return err
+	}
+
+	trimClient := u.trimClient
+	if settingsChanged(prev, next, flgFnosAddress, flgFnosUsername, flgFnosPassword, flgDumpProtocol) {
+		slog.InfoContext(ctx, "fnos settings changed, reconnect", "to", next.String(flgFnosAddress))
+
+		if trimClient, err = connect(next); err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				trimClient.Close()
+			}
+		}()
+	}
+
+	notifier := u.notifier
+	if settingsChanged(prev, next, flgNotify) {
+		if notifier, err = notify.NewDispatcher(next.StringSlice(flgNotify)); err != nil {
+			return err
+		}
+	}
+
+	// the dns provider reads its credentials from the environment, which may be exported from the config file
+	configEnv := exportedConfigEnv()
+
+	legoClient := u.legoClient
+	if settingsChanged(prev, next, flgEmail, flgDnsProvider, flgDnsResolvers) || !maps.Equal(u.configEnv, configEnv) {
+		slog.InfoContext(ctx, "acme settings changed, recreate client")
+
+		if legoClient, err = acme(next); err != nil {
+			return err
+		}
+	}
+
+	// the certificate of the removed group is archived, so it is no longer checked and deployed,
+	// the copy on the NAS is left as is
+	if group := next.StringSlice(flgDomains)[0]; group != u.domains[0] {
+		slog.InfoContext(ctx, "group removed, archive its certificate", "group", u.domains[0], "next", group)
+
+		if err := archiveCertificate(u.dataDir, u.domains[0]); err != nil {
+			return err
+		}
+	}
+
+	if trimClient != u.trimClient {
+		slog.InfoContext(ctx, "target removed, certificates on it are left as is", "nas", u.nas)
+		u.trimClient.Close()
+	}
+
+	u.nas = next.String(flgFnosAddress)
+	u.domains = next.StringSlice(flgDomains)
+	u.renewDays = int(next.Int(flgRenewDays))
+	u.approval = next.Bool(flgRequireApproval)
+	u.approvalDeadline = next.Duration(flgApprovalDeadline)
+	u.hooks = hooksFromCommand(next)
+	u.windows = windows
+	u.notifier = notifier
+	u.legoClient = legoClient
+	u.trimClient = trimClient
+	u.configEnv = configEnv
+
+	for _, name := range restartSettings {
+		if settingsChanged(prev, next, name) {
+			slog.WarnContext(ctx, "setting changed, restart to apply it", "setting", name)
+		}
+	}
+
+	u.journal.recordConfig(configSettings(next))
+
+	slog.InfoContext(ctx, "config reloaded")
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/cospotato/fnos-acme/internal/trim/rpc"
+	rpcerrors "github.com/cospotato/fnos-acme/internal/trim/rpc/errors"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceExporterNone     = "none"
+	traceExporterOTLPGRPC = "otlp-grpc"
+	traceExporterOTLPHTTP = "otlp-http"
+)
+
+var tracer = otel.Tracer("github.com/cospotato/fnos-acme")
+
+// setupTracing installs the global tracer provider, the returned function flushes and stops it.
+// Endpoint and headers fall back to the standard OTEL_EXPORTER_OTLP_* environment variables.
+func setupTracing(ctx context.Context, c *cli.Command) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	endpoint := c.String(flgTraceEndpoint)
+
+	switch c.String(flgTraceExporter) {
+	case "", traceExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case traceExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+		}
+
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case traceExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", c.String(flgTraceExporter))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("fnos-acme"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.Float(flgTraceSampleRatio)))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Error("trace export failed", "err", err)
+	}))
+
+	return provider.Shutdown, nil
+}
+
+// endSpan records the error of the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// tracingInterceptor creates a span for each fnos rpc.
+func tracingInterceptor(ctx context.Context, method string, req, reply any, cc *rpc.ClientConn, invoker rpc.UnaryInvoker, opts ...rpc.CallOption) error {
+	ctx, span := tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("fnos"),
+			semconv.RPCMethod(method),
+		),
+	)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		span.SetAttributes(attribute.String("rpc.fnos.errno", strconv.FormatUint(uint64(rpcerrors.Code(err)), 10)))
+	}
+
+	endSpan(span, err)
+
+	return err
+}
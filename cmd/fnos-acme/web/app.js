@@ -0,0 +1,182 @@
+'use strict';
+
+// the token is kept for the browser session only
+const tokenKey = 'fnos-acme-token';
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === 'class') e.className = v;
+    else if (k.startsWith('on')) e.addEventListener(k.slice(2), v);
+    else e.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c !== null && c !== undefined) e.append(c instanceof Node ? c : String(c));
+  }
+  return e;
+}
+
+async function api(method, path) {
+  const resp = await fetch('api/v1/' + path, {
+    method,
+    headers: { Authorization: 'Bearer ' + sessionStorage.getItem(tokenKey) },
+  });
+
+  if (resp.status === 401) {
+    signOut();
+    throw new Error('unauthorized');
+  }
+
+  const body = await resp.json();
+  if (!resp.ok) throw new Error(body.error || resp.statusText);
+
+  return body;
+}
+
+function formatTime(v) {
+  if (!v || v.startsWith('0001-')) return '-';
+  return new Date(v).toLocaleString();
+}
+
+function daysLeft(v) {
+  return Math.floor((new Date(v) - Date.now()) / 86400000);
+}
+
+function expiryBadge(v) {
+  const days = daysLeft(v);
+  const cls = days <= 7 ? 'bad' : days <= 30 ? 'warn' : 'ok';
+  return el('span', { class: 'badge ' + cls, title: formatTime(v) }, days + ' days');
+}
+
+const nasStateClass = { deployed: 'ok', stale: 'warn', pending: 'warn', missing: 'bad' };
+
+function showMessage(text, error) {
+  const m = $('message');
+  m.textContent = text;
+  m.className = error ? 'error' : '';
+  m.hidden = false;
+}
+
+async function action(button, method, path) {
+  button.disabled = true;
+  try {
+    const reply = await api(method, path);
+    showMessage(reply.message || 'done', false);
+  } catch (e) {
+    showMessage(e.message, true);
+  } finally {
+    button.disabled = false;
+    refresh();
+  }
+}
+
+function renderGroups(data) {
+  $('next-check').textContent = 'next check ' + formatTime(data.nextCheck);
+
+  const rows = (data.certificates || []).map((c) => {
+    const group = encodeURIComponent(c.group);
+
+    return el('tr', null,
+      el('td', null, c.group, (data.pendingApproval || []).includes(c.group) ? el('div', { class: 'muted' }, 'new certificate waiting for approval') : null),
+      el('td', null, (c.domains || []).join(', ')),
+      el('td', null, c.source),
+      el('td', null, expiryBadge(c.notAfter)),
+      el('td', null,
+        el('span', { class: 'badge ' + (nasStateClass[c.nasState] || 'warn') }, c.nasState),
+        c.nasCertId ? el('div', { class: 'muted' }, 'id ' + c.nasCertId) : null),
+      el('td', null, c.lastError || '-'),
+      el('td', null,
+        c.source === 'acme' ? el('button', { onclick: (e) => action(e.target, 'POST', 'groups/' + group + '/renew') }, 'Renew') : null,
+        ' ',
+        el('button', { onclick: (e) => action(e.target, 'POST', 'groups/' + group + '/deploy') }, 'Deploy')),
+    );
+  });
+
+  $('groups').replaceChildren(...rows);
+}
+
+function renderTargets(data) {
+  const sections = (data.targets || []).map((t) => {
+    const rows = (t.certificates || []).map((c) => el('tr', null,
+      el('td', null, c.id),
+      el('td', null, c.domain, c.default ? el('span', { class: 'muted' }, ' (default)') : null),
+      el('td', null, c.san || '-'),
+      el('td', null, c.issuedBy || '-'),
+      el('td', null, c.status),
+      el('td', null, c.validTo.startsWith('0001-') ? '-' : expiryBadge(c.validTo)),
+    ));
+
+    return el('div', null,
+      el('h3', null, t.address, ' ', el('span', { class: 'badge ' + (t.connected ? 'ok' : 'bad') }, t.connected ? 'connected' : 'disconnected')),
+      t.error ? el('div', { class: 'muted' }, t.error) : null,
+      el('table', null,
+        el('thead', null, el('tr', null, ...['ID', 'Domain', 'SAN', 'Issuer', 'Status', 'Valid to'].map((h) => el('th', null, h)))),
+        el('tbody', null, ...rows)),
+    );
+  });
+
+  $('targets').replaceChildren(...sections);
+}
+
+function renderEvents(data) {
+  const rows = (data.entries || []).reverse().map((e) => {
+    const detail = Object.entries(e.detail || {}).map(([k, v]) => k + '=' + v);
+    if (e.error) detail.push('error=' + e.error);
+
+    return el('tr', null,
+      el('td', null, formatTime(e.time)),
+      el('td', null, e.type),
+      el('td', null, e.group || '-'),
+      el('td', { class: e.error ? 'muted' : '' }, detail.join(' ')),
+    );
+  });
+
+  $('events').replaceChildren(...rows);
+}
+
+async function refresh() {
+  try {
+    const [groups, targets, history] = await Promise.all([
+      api('GET', 'groups'),
+      api('GET', 'targets'),
+      api('GET', 'history?limit=30'),
+    ]);
+
+    renderGroups(groups);
+    renderTargets(targets);
+    renderEvents(history);
+  } catch (e) {
+    if (sessionStorage.getItem(tokenKey)) showMessage(e.message, true);
+  }
+}
+
+function signOut() {
+  sessionStorage.removeItem(tokenKey);
+  $('dashboard').hidden = true;
+  $('login').hidden = false;
+}
+
+function start() {
+  $('login').hidden = true;
+  $('dashboard').hidden = false;
+  refresh();
+}
+
+$('login').addEventListener('submit', (e) => {
+  e.preventDefault();
+  sessionStorage.setItem(tokenKey, $('token').value);
+  $('token').value = '';
+  start();
+});
+
+$('logout').addEventListener('click', signOut);
+$('check').addEventListener('click', (e) => action(e.target, 'POST', 'check'));
+
+setInterval(() => {
+  if (sessionStorage.getItem(tokenKey) && !document.hidden) refresh();
+}, 60000);
+
+if (sessionStorage.getItem(tokenKey)) start();
+else signOut();
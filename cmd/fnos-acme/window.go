@@ -0,0 +1,117 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a daily time range in local time, end before start means crossing midnight.
+type maintenanceWindow struct {
+	startHour, startMinute int
+	endHour, endMinute     int
+}
+
+type maintenanceWindows []maintenanceWindow
+
+func parseClock(s string) (int, int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expect HH:MM", s)
+	}
+
+	return t.Hour(), t.Minute(), nil
+}
+
+func parseMaintenanceWindows(values []string) (maintenanceWindows, error) {
+	windows := make(maintenanceWindows, 0, len(values))
+
+	for _, v := range values {
+		start, end, ok := strings.Cut(v, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid maintenance window %q, expect HH:MM-HH:MM", v)
+		}
+
+		var (
+			w   maintenanceWindow
+			err error
+		)
+
+		if w.startHour, w.startMinute, err = parseClock(start); err != nil {
+			return nil, err
+		}
+
+		if w.endHour, w.endMinute, err = parseClock(end); err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, w)
+	}
+
+	return windows, nil
+}
+
+// bounds returns the occurrence of the window starting on the day of t.
+func (w maintenanceWindow) bounds(t time.Time) (time.Time, time.Time) {
+	y, m, d := t.Date()
+	start := time.Date(y, m, d, w.startHour, w.startMinute, 0, 0, t.Location())
+	end := time.Date(y, m, d, w.endHour, w.endMinute, 0, 0, t.Location())
+
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return start, end
+}
+
+// contains reports whether t is inside any window, no windows means always open.
+func (ws maintenanceWindows) contains(t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+
+	for _, w := range ws {
+		for _, day := range []int{-1, 0} {
+			start, end := w.bounds(t.AddDate(0, 0, day))
+			if !t.Before(start) && t.Before(end) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// nextStart returns the nearest window start after t, or zero time if no windows.
+func (ws maintenanceWindows) nextStart(t time.Time) time.Time {
+	var next time.Time
+
+	for _, w := range ws {
+		for _, day := range []int{0, 1} {
+			start, _ := w.bounds(t.AddDate(0, 0, day))
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+
+	return next
+}
+
+// deferReplace returns the next window start if replacing the remote certificate expiring at validTo waits
+// for it, that is t is outside the windows and the remote one outlives the wait. An unknown expiry, zero
+// validTo, never waits as the remote one may have expired already.
+func (ws maintenanceWindows) deferReplace(t, validTo time.Time) (time.Time, bool) {
+	if validTo.IsZero() || ws.contains(t) {
+		return time.Time{}, false
+	}
+
+	open := ws.nextStart(t)
+
+	return open, validTo.After(open)
+}
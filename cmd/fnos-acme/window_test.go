@@ -0,0 +1,47 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeferReplace(t *testing.T) {
+	windows, err := parseMaintenanceWindows([]string{"03:00-04:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	open := time.Date(2025, 6, 2, 3, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		windows  maintenanceWindows
+		now      time.Time
+		validTo  time.Time
+		deferred bool
+	}{
+		{"outlives the wait", windows, now, now.Add(30 * 24 * time.Hour), true},
+		{"expires before window", windows, now, now.Add(time.Hour), false},
+		{"unknown expiry", windows, now, time.Time{}, false},
+		{"inside window", windows, open.Add(30 * time.Minute), now.Add(30 * 24 * time.Hour), false},
+		{"no windows", nil, now, now.Add(30 * 24 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.windows.deferReplace(tt.now, tt.validTo)
+		if ok != tt.deferred {
+			t.Errorf("%s: deferred = %v, want %v", tt.name, ok, tt.deferred)
+			continue
+		}
+
+		if ok && !got.Equal(open) {
+			t.Errorf("%s: window open = %v, want %v", tt.name, got, open)
+		}
+	}
+}
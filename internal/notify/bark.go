@@ -0,0 +1,63 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const barkEndpoint = "https://api.day.app"
+
+// bark pushes to iOS by Bark, bark://<device-key>.
+type bark struct {
+	url       string
+	deviceKey string
+}
+
+func init() {
+	register("bark", func(u *url.URL, o *options) (Notifier, error) {
+		if u.Host == "" {
+			return nil, errors.New("missing device key")
+		}
+
+		return &bark{
+			url:       endpointOr(o.endpoint, barkEndpoint) + "/push",
+			deviceKey: u.Host,
+		}, nil
+	})
+}
+
+type barkReply struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (b *bark) Notify(ctx context.Context, e Event) error {
+	body := map[string]any{
+		"device_key": b.deviceKey,
+		"title":      e.Title(),
+		"body":       e.Message(),
+		"group":      "fnos-acme",
+	}
+
+	if e.Type == EventFailed || e.Type == EventExpiring {
+		body["level"] = "timeSensitive"
+	}
+
+	var reply barkReply
+	if err := postJSON(ctx, b.url, body, &reply); err != nil {
+		return err
+	}
+
+	if reply.Code != 200 {
+		return fmt.Errorf("code %d: %s", reply.Code, reply.Message)
+	}
+
+	return nil
+}
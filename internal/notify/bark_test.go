@@ -0,0 +1,71 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBark(t *testing.T) {
+	s := newStandIn(t, `{"code":200,"message":"success"}`)
+	n := parseNotifier(t, "bark://DEVICEKEY?endpoint="+s.URL)
+
+	e := testEvent(EventFailed)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Method != "POST" || r.Path != "/push" || r.ContentType != "application/json" {
+		t.Fatalf("got %s %s %s", r.Method, r.Path, r.ContentType)
+	}
+
+	body := r.jsonBody(t)
+	want := map[string]string{
+		"device_key": "DEVICEKEY",
+		"title":      e.Title(),
+		"body":       e.Message(),
+		"group":      "fnos-acme",
+		"level":      "timeSensitive",
+	}
+
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %q", k, body[k], v)
+		}
+	}
+}
+
+func TestBarkLevel(t *testing.T) {
+	s := newStandIn(t, `{"code":200}`)
+	n := parseNotifier(t, "bark://DEVICEKEY?endpoint="+s.URL)
+
+	if err := n.Notify(context.Background(), testEvent(EventDeployed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if level, ok := s.last(t).jsonBody(t)["level"]; ok {
+		t.Fatalf("level = %v, want none for deployed", level)
+	}
+}
+
+func TestBarkError(t *testing.T) {
+	s := newStandIn(t, `{"code":400,"message":"failed to get device token"}`)
+	n := parseNotifier(t, "bark://DEVICEKEY?endpoint="+s.URL)
+
+	err := n.Notify(context.Background(), testEvent(EventFailed))
+	if err == nil || !strings.Contains(err.Error(), "failed to get device token") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestBarkMissingKey(t *testing.T) {
+	if _, err := Parse("bark://"); err == nil {
+		t.Fatal("want error without device key")
+	}
+}
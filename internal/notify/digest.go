@@ -0,0 +1,202 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DigestCert is the state of a managed certificate in the digest.
+type DigestCert struct {
+	Group       string    `json:"group"`
+	Domains     []string  `json:"domains"`
+	Source      string    `json:"source"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+	NAS         string    `json:"nas"`
+	NASState    string    `json:"nasState"`
+	NASCert     int       `json:"nasCertId,omitempty"`
+	NASNotAfter time.Time `json:"nasNotAfter,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// DaysLeft returns the whole days until the certificate expires.
+func (c DigestCert) DaysLeft() int {
+	return int(time.Until(c.NotAfter).Hours() / 24)
+}
+
+// Digest is the periodic report of all managed certificates and recent errors.
+type Digest struct {
+	Certificates []DigestCert `json:"certificates"`
+	Errors       []Event      `json:"errors,omitempty"`
+	Time         time.Time    `json:"time"`
+}
+
+func (d Digest) Title() string {
+	expiring := 0
+	for _, c := range d.Certificates {
+		if c.DaysLeft() <= defaultExpiringDays {
+			expiring++
+		}
+	}
+
+	return fmt.Sprintf("fnos-acme: digest, %d certificates, %d expiring, %d errors", len(d.Certificates), expiring, len(d.Errors))
+}
+
+func (d Digest) Message() string {
+	var b strings.Builder
+
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSOURCE\tEXPIRES\tDAYS\tNAS\tNAS CERT")
+
+	for _, c := range d.Certificates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\n", c.Group, c.Source, c.NotAfter.Local().Format(time.DateOnly), c.DaysLeft(), c.NASState, c.NASCert)
+	}
+
+	_ = w.Flush()
+
+	for _, c := range d.Certificates {
+		if c.LastError != "" {
+			fmt.Fprintf(&b, "\n%s pending deployment: %s\n", c.Group, c.LastError)
+		}
+	}
+
+	if len(d.Errors) > 0 {
+		b.WriteString("\nrecent errors:\n")
+
+		for _, e := range d.Errors {
+			group := e.Group
+			if group == "" {
+				group = "-"
+			}
+
+			fmt.Fprintf(&b, "%s  %s  %s\n", e.Time.Local().Format(time.DateTime), group, e.Error)
+		}
+	}
+
+	fmt.Fprintf(&b, "\ntime: %s", d.Time.Local().Format(time.RFC3339))
+
+	return b.String()
+}
+
+// DigestNotifier is a notifier able to send the digest.
+type DigestNotifier interface {
+	NotifyDigest(ctx context.Context, d Digest) error
+}
+
+// digestSchedule sends digest daily or weekly on Monday at the local time.
+type digestSchedule struct {
+	weekly bool
+	hour   int
+	minute int
+}
+
+func parseDigestSchedule(period, at string) (*digestSchedule, error) {
+	s := &digestSchedule{hour: 9}
+
+	switch period {
+	case "off":
+		return nil, nil
+	case "daily":
+	case "", "weekly":
+		s.weekly = true
+	default:
+		return nil, fmt.Errorf("invalid digest %q, must be daily, weekly or off", period)
+	}
+
+	if at != "" {
+		t, err := time.Parse("15:04", at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest-at %q", at)
+		}
+
+		s.hour, s.minute = t.Hour(), t.Minute()
+	}
+
+	return s, nil
+}
+
+// next returns the first digest time after t.
+func (s *digestSchedule) next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.hour, s.minute, 0, 0, t.Location())
+
+	if s.weekly {
+		next = next.AddDate(0, 0, -(int(next.Weekday())+6)%7)
+	}
+
+	for !next.After(t) {
+		if s.weekly {
+			next = next.AddDate(0, 0, 7)
+		} else {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+
+	return next
+}
+
+// NextDigest returns the earliest digest time of the targets, or zero time if no target sends digest.
+func (d *Dispatcher) NextDigest() time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Time
+
+	for _, t := range d.nextDigest {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// SendDigest sends the digest to the targets whose digest time has come.
+func (d *Dispatcher) SendDigest(ctx context.Context, digest Digest) error {
+	if d == nil {
+		return nil
+	}
+
+	if digest.Time.IsZero() {
+		digest.Time = time.Now()
+	}
+
+	var errs []error
+
+	for i, t := range d.targets {
+		d.mu.Lock()
+		next, ok := d.nextDigest[i]
+		if ok && !next.After(digest.Time) {
+			d.nextDigest[i] = t.digest.next(digest.Time)
+		}
+		d.mu.Unlock()
+
+		if !ok || next.After(digest.Time) {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := t.Notifier.(DigestNotifier).NotifyDigest(sendCtx, digest)
+		cancel()
+
+		if err != nil {
+			slog.Error("send digest failed", "notifier", t.Name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const dingtalkEndpoint = "https://oapi.dingtalk.com"
+
+// dingtalk sends to the custom robot of DingTalk, dingtalk://<access-token>?secret=<secret>.
+type dingtalk struct {
+	url    string
+	secret string
+}
+
+func init() {
+	register("dingtalk", func(u *url.URL, o *options) (Notifier, error) {
+		if u.Host == "" {
+			return nil, errors.New("missing access token")
+		}
+
+		return &dingtalk{
+			url:    endpointOr(o.endpoint, dingtalkEndpoint) + "/robot/send?access_token=" + url.QueryEscape(u.Host),
+			secret: u.Query().Get("secret"),
+		}, nil
+	})
+}
+
+type dingtalkReply struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// sign signs timestamp with the secret of the robot in security settings.
+func (d *dingtalk) sign(timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(timestamp + "\n" + d.secret))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (d *dingtalk) Notify(ctx context.Context, e Event) error {
+	target := d.url
+
+	if d.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		target += "&timestamp=" + timestamp + "&sign=" + url.QueryEscape(d.sign(timestamp))
+	}
+
+	body := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": e.Title(),
+			"text":  fmt.Sprintf("### %s\n\n%s", e.Title(), e.Message()),
+		},
+	}
+
+	var reply dingtalkReply
+	if err := postJSON(ctx, target, body, &reply); err != nil {
+		return err
+	}
+
+	if reply.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", reply.ErrCode, reply.ErrMsg)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDingTalk(t *testing.T) {
+	s := newStandIn(t, `{"errcode":0,"errmsg":"ok"}`)
+	n := parseNotifier(t, "dingtalk://TOKEN?endpoint="+s.URL)
+
+	e := testEvent(EventIssued)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/robot/send" || r.Query.Get("access_token") != "TOKEN" {
+		t.Fatalf("got %s?%s", r.Path, r.Query.Encode())
+	}
+
+	if r.Query.Has("sign") || r.Query.Has("timestamp") {
+		t.Fatalf("signed without secret: %s", r.Query.Encode())
+	}
+
+	body := r.jsonBody(t)
+	if body["msgtype"] != "markdown" {
+		t.Fatalf("msgtype = %v", body["msgtype"])
+	}
+
+	markdown := body["markdown"].(map[string]any)
+	if markdown["title"] != e.Title() || !strings.Contains(markdown["text"].(string), e.Message()) {
+		t.Fatalf("markdown = %v", markdown)
+	}
+}
+
+func TestDingTalkSign(t *testing.T) {
+	const secret = "SEC000"
+
+	s := newStandIn(t, `{"errcode":0,"errmsg":"ok"}`)
+	n := parseNotifier(t, "dingtalk://TOKEN?secret="+secret+"&endpoint="+s.URL)
+
+	before := time.Now().UnixMilli()
+	if err := n.Notify(context.Background(), testEvent(EventFailed)); err != nil {
+		t.Fatal(err)
+	}
+
+	q := s.last(t).Query
+
+	timestamp, err := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+	if err != nil || timestamp < before || timestamp > time.Now().UnixMilli() {
+		t.Fatalf("timestamp = %q, want milliseconds of now", q.Get("timestamp"))
+	}
+
+	// the signature documented by DingTalk: base64(hmac-sha256(secret, timestamp + "\n" + secret))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(q.Get("timestamp") + "\n" + secret))
+
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); q.Get("sign") != want {
+		t.Fatalf("sign = %q, want %q", q.Get("sign"), want)
+	}
+}
+
+func TestDingTalkError(t *testing.T) {
+	s := newStandIn(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	n := parseNotifier(t, "dingtalk://TOKEN?secret=x&endpoint="+s.URL)
+
+	err := n.Notify(context.Background(), testEvent(EventFailed))
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Fatalf("got %v", err)
+	}
+}
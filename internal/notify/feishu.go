@@ -0,0 +1,77 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const feishuEndpoint = "https://open.feishu.cn"
+
+// feishu sends to the custom bot of Feishu, feishu://<hook-id>?secret=<secret>.
+type feishu struct {
+	url    string
+	secret string
+}
+
+func init() {
+	register("feishu", func(u *url.URL, o *options) (Notifier, error) {
+		if u.Host == "" {
+			return nil, errors.New("missing hook id")
+		}
+
+		return &feishu{
+			url:    endpointOr(o.endpoint, feishuEndpoint) + "/open-apis/bot/v2/hook/" + url.PathEscape(u.Host),
+			secret: u.Query().Get("secret"),
+		}, nil
+	})
+}
+
+type feishuReply struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// sign signs timestamp with the secret of the bot, the key is timestamp and secret with empty message.
+func (f *feishu) sign(timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+f.secret))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (f *feishu) Notify(ctx context.Context, e Event) error {
+	body := map[string]any{
+		"msg_type": "text",
+		"content": map[string]string{
+			"text": e.Title() + "\n" + e.Message(),
+		},
+	}
+
+	if f.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		body["timestamp"] = timestamp
+		body["sign"] = f.sign(timestamp)
+	}
+
+	var reply feishuReply
+	if err := postJSON(ctx, f.url, body, &reply); err != nil {
+		return err
+	}
+
+	if reply.Code != 0 {
+		return fmt.Errorf("code %d: %s", reply.Code, reply.Msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestFeishu(t *testing.T) {
+	s := newStandIn(t, `{"code":0,"msg":"success"}`)
+	n := parseNotifier(t, "feishu://HOOK?endpoint="+s.URL)
+
+	e := testEvent(EventIssued)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/open-apis/bot/v2/hook/HOOK" {
+		t.Fatalf("path = %s", r.Path)
+	}
+
+	body := r.jsonBody(t)
+	if body["msg_type"] != "text" || body["content"].(map[string]any)["text"] != e.Title()+"\n"+e.Message() {
+		t.Fatalf("body = %v", body)
+	}
+
+	if _, ok := body["sign"]; ok {
+		t.Fatal("signed without secret")
+	}
+}
+
+func TestFeishuSign(t *testing.T) {
+	const secret = "SEC"
+
+	s := newStandIn(t, `{"code":0}`)
+	n := parseNotifier(t, "feishu://HOOK?secret="+secret+"&endpoint="+s.URL)
+
+	if err := n.Notify(context.Background(), testEvent(EventFailed)); err != nil {
+		t.Fatal(err)
+	}
+
+	body := s.last(t).jsonBody(t)
+	timestamp := body["timestamp"].(string)
+
+	// the signature documented by Feishu: base64(hmac-sha256(timestamp + "\n" + secret, ""))
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); body["sign"] != want {
+		t.Fatalf("sign = %v, want %q", body["sign"], want)
+	}
+}
+
+func TestFeishuError(t *testing.T) {
+	s := newStandIn(t, `{"code":19021,"msg":"sign match fail"}`)
+	n := parseNotifier(t, "feishu://HOOK?endpoint="+s.URL)
+
+	err := n.Notify(context.Background(), testEvent(EventFailed))
+	if err == nil || !strings.Contains(err.Error(), "19021") {
+		t.Fatalf("got %v", err)
+	}
+}
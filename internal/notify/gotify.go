@@ -0,0 +1,54 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// gotify sends to a Gotify server, gotify://<host>/<app-token>, gotify+http for plain http server.
+type gotify struct {
+	url string
+}
+
+func init() {
+	newGotify := func(u *url.URL, o *options) (Notifier, error) {
+		token := strings.Trim(u.Path, "/")
+		if u.Host == "" || token == "" {
+			return nil, errors.New("url must be gotify://<host>/<app-token>")
+		}
+
+		scheme := "https"
+		if u.Scheme == "gotify+http" {
+			scheme = "http"
+		}
+
+		return &gotify{
+			url: endpointOr(o.endpoint, scheme+"://"+u.Host) + "/message?token=" + url.QueryEscape(token),
+		}, nil
+	}
+
+	register("gotify", newGotify)
+	register("gotify+http", newGotify)
+}
+
+func (g *gotify) Notify(ctx context.Context, e Event) error {
+	priority := 5
+	if e.Type == EventFailed || e.Type == EventNASUnreachable {
+		priority = 8
+	}
+
+	body := map[string]any{
+		"title":    e.Title(),
+		"message":  e.Message(),
+		"priority": priority,
+	}
+
+	return postJSON(ctx, g.url, body, nil)
+}
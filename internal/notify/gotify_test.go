@@ -0,0 +1,51 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGotify(t *testing.T) {
+	s := newStandIn(t, `{"id":1}`)
+	n := parseNotifier(t, "gotify+http://"+strings.TrimPrefix(s.URL, "http://")+"/APPTOKEN")
+
+	e := testEvent(EventNASUnreachable)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/message" || r.Query.Get("token") != "APPTOKEN" {
+		t.Fatalf("got %s?%s", r.Path, r.Query.Encode())
+	}
+
+	body := r.jsonBody(t)
+	if body["title"] != e.Title() || body["message"] != e.Message() || body["priority"] != float64(8) {
+		t.Fatalf("body = %v", body)
+	}
+}
+
+func TestGotifyPriority(t *testing.T) {
+	s := newStandIn(t, `{}`)
+	n := parseNotifier(t, "gotify://gotify.example/APPTOKEN?endpoint="+s.URL)
+
+	if err := n.Notify(context.Background(), testEvent(EventDeployed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if priority := s.last(t).jsonBody(t)["priority"]; priority != float64(5) {
+		t.Fatalf("priority = %v, want 5", priority)
+	}
+}
+
+func TestGotifyMissingToken(t *testing.T) {
+	if _, err := Parse("gotify://gotify.example"); err == nil {
+		t.Fatal("want error without app token")
+	}
+}
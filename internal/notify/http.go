@@ -0,0 +1,92 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+var httpClient = &http.Client{}
+
+// postJSON posts body as json and decodes the response into reply if not nil.
+func postJSON(ctx context.Context, url string, body any, reply any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, url, "application/json", bytes.NewReader(data), reply)
+}
+
+func postForm(ctx context.Context, url string, form url.Values, reply any) error {
+	return post(ctx, url, "application/x-www-form-urlencoded", bytes.NewBufferString(form.Encode()), reply)
+}
+
+func post(ctx context.Context, target, contentType string, body io.Reader, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return redactURLError(err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	if reply == nil || len(data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, reply); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// redactURLError removes the url from the error, as most services carry the token in it.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	host := "url"
+	if u, err := url.Parse(urlErr.URL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	return fmt.Errorf("%s %s: %w", urlErr.Op, host, urlErr.Err)
+}
+
+// endpointOr returns the endpoint if set, or the default api server.
+func endpointOr(endpoint, def string) string {
+	if endpoint != "" {
+		return endpoint
+	}
+
+	return def
+}
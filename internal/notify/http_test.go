@@ -0,0 +1,135 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capturedRequest is a request received by the stand-in server.
+type capturedRequest struct {
+	Method      string
+	Path        string
+	Query       url.Values
+	ContentType string
+	Body        []byte
+}
+
+// jsonBody decodes the body of the request as a json object.
+func (r capturedRequest) jsonBody(t *testing.T) map[string]any {
+	t.Helper()
+
+	var body map[string]any
+	if err := json.Unmarshal(r.Body, &body); err != nil {
+		t.Fatalf("decode body %q: %v", r.Body, err)
+	}
+
+	return body
+}
+
+// standIn is a local http server in place of the api server of a backend.
+type standIn struct {
+	*httptest.Server
+	requests []capturedRequest
+	status   int
+	reply    string
+}
+
+// newStandIn starts a server which records the requests and replies with the reply as json.
+func newStandIn(t *testing.T, reply string) *standIn {
+	t.Helper()
+
+	s := &standIn{status: http.StatusOK, reply: reply}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+
+		s.requests = append(s.requests, capturedRequest{
+			Method:      r.Method,
+			Path:        r.URL.EscapedPath(),
+			Query:       r.URL.Query(),
+			ContentType: r.Header.Get("Content-Type"),
+			Body:        data,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.status)
+		_, _ = io.WriteString(w, s.reply)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// last returns the only request received by the server.
+func (s *standIn) last(t *testing.T) capturedRequest {
+	t.Helper()
+
+	if len(s.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(s.requests))
+	}
+
+	return s.requests[0]
+}
+
+// parseNotifier parses the url of the target and returns its notifier.
+func parseNotifier(t *testing.T, rawURL string) Notifier {
+	t.Helper()
+
+	target, err := Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", rawURL, err)
+	}
+
+	return target.Notifier
+}
+
+func testEvent(typ EventType) Event {
+	return Event{
+		Type:     typ,
+		Group:    "example.com",
+		Domains:  []string{"example.com", "*.example.com"},
+		NAS:      "http://nas:5666",
+		NotAfter: time.Now().Add(5 * 24 * time.Hour),
+		Error:    "boom",
+		Time:     time.Now(),
+	}
+}
+
+func TestPostErrorHidesURL(t *testing.T) {
+	s := newStandIn(t, "{}")
+	s.Close()
+
+	err := postJSON(context.Background(), s.URL+"/bot123456:SECRET/sendMessage?key=SECRET", map[string]string{}, nil)
+	if err == nil {
+		t.Fatal("want error from closed server")
+	}
+
+	if strings.Contains(err.Error(), "SECRET") {
+		t.Fatalf("error leaks the token: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), strings.TrimPrefix(s.URL, "http://")) {
+		t.Fatalf("error does not name the host: %v", err)
+	}
+}
+
+func TestPostStatusError(t *testing.T) {
+	s := newStandIn(t, `{"error":"bad request"}`)
+	s.status = http.StatusBadRequest
+
+	err := postJSON(context.Background(), s.URL+"/hook", map[string]string{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("got %v, want status error", err)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventIssued         EventType = "issued"
+	EventDeployed       EventType = "deployed"
+	EventFailed         EventType = "failed"
+	EventExpiring       EventType = "expiring"
+	EventNASUnreachable EventType = "nas-unreachable"
+)
+
+var allEvents = []EventType{EventIssued, EventDeployed, EventFailed, EventExpiring, EventNASUnreachable}
+
+// throttles limits how often the same event of a group is sent to a target,
+// failures are throttled by their error so that retries of the same failure keep quiet.
+var throttles = map[EventType]time.Duration{
+	EventFailed:         time.Hour,
+	EventExpiring:       24 * time.Hour,
+	EventNASUnreachable: time.Hour,
+}
+
+const (
+	defaultExpiringDays = 7
+	sendTimeout         = 30 * time.Second
+)
+
+type Event struct {
+	Type     EventType `json:"type"`
+	Group    string    `json:"group,omitempty"`
+	Domains  []string  `json:"domains,omitempty"`
+	NAS      string    `json:"nas,omitempty"`
+	NASCert  int       `json:"nasCertId,omitempty"`
+	NotAfter time.Time `json:"notAfter,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func (e Event) Title() string {
+	switch e.Type {
+	case EventIssued:
+		return fmt.Sprintf("fnos-acme: certificate %s issued", e.Group)
+	case EventDeployed:
+		return fmt.Sprintf("fnos-acme: certificate %s deployed", e.Group)
+	case EventFailed:
+		if e.Group == "" {
+			return "fnos-acme: failed"
+		}
+
+		return fmt.Sprintf("fnos-acme: certificate %s failed", e.Group)
+	case EventExpiring:
+		return fmt.Sprintf("fnos-acme: certificate %s expiring in %d days", e.Group, e.DaysLeft())
+	case EventNASUnreachable:
+		return fmt.Sprintf("fnos-acme: NAS %s unreachable", e.NAS)
+	default:
+		return "fnos-acme: " + string(e.Type)
+	}
+}
+
+func (e Event) Message() string {
+	var b strings.Builder
+
+	if e.Group != "" {
+		fmt.Fprintf(&b, "group: %s\n", e.Group)
+	}
+
+	if len(e.Domains) > 0 {
+		fmt.Fprintf(&b, "domains: %s\n", strings.Join(e.Domains, ", "))
+	}
+
+	if e.NAS != "" {
+		fmt.Fprintf(&b, "nas: %s\n", e.NAS)
+	}
+
+	if e.NASCert != 0 {
+		fmt.Fprintf(&b, "nas cert id: %d\n", e.NASCert)
+	}
+
+	if !e.NotAfter.IsZero() {
+		fmt.Fprintf(&b, "expires: %s (%d days)\n", e.NotAfter.Local().Format(time.RFC3339), e.DaysLeft())
+	}
+
+	if e.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", e.Error)
+	}
+
+	fmt.Fprintf(&b, "time: %s", e.Time.Local().Format(time.RFC3339))
+
+	return b.String()
+}
+
+// DaysLeft returns the whole days until the certificate expires.
+func (e Event) DaysLeft() int {
+	return int(time.Until(e.NotAfter).Hours() / 24)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Target is a notifier with the events it subscribes.
+type Target struct {
+	Notifier
+	Name         string
+	Events       map[EventType]bool
+	ExpiringDays int
+
+	digest *digestSchedule
+}
+
+func (t *Target) accept(e Event) bool {
+	if !t.Events[e.Type] {
+		return false
+	}
+
+	if e.Type == EventExpiring {
+		return e.DaysLeft() <= t.ExpiringDays
+	}
+
+	return true
+}
+
+// options are the dependencies of notifiers besides the url.
+type options struct {
+	endpoint string
+}
+
+type Option func(o *options)
+
+type factory func(u *url.URL, o *options) (Notifier, error)
+
+var factories = map[string]factory{}
+
+func register(scheme string, f factory) {
+	factories[scheme] = f
+}
+
+// Parse creates a target from url like wecom://<key>?events=failed+expiring&expiring-days=7,
+// the endpoint parameter overrides the api server of the backend.
+func Parse(rawURL string, opts ...Option) (*Target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := factories[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier %q", u.Scheme)
+	}
+
+	q := u.Query()
+
+	t := &Target{
+		Name:         u.Scheme,
+		Events:       make(map[EventType]bool),
+		ExpiringDays: defaultExpiringDays,
+	}
+
+	if v := q.Get("events"); v != "" {
+		for _, name := range strings.FieldsFunc(v, func(r rune) bool { return r == '|' || r == ' ' || r == ',' }) {
+			if !slices.Contains(allEvents, EventType(name)) {
+				return nil, fmt.Errorf("unknown event %q", name)
+			}
+
+			t.Events[EventType(name)] = true
+		}
+	}
+
+	if v := q.Get("expiring-days"); v != "" {
+		if t.ExpiringDays, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid expiring-days %q", v)
+		}
+	}
+
+	o := &options{
+		endpoint: strings.TrimSuffix(q.Get("endpoint"), "/"),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	t.Notifier, err = f(u, o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u.Scheme, err)
+	}
+
+	if len(t.Events) == 0 {
+		events := allEvents
+		if d, ok := t.Notifier.(interface{ defaultEvents() []EventType }); ok {
+			events = d.defaultEvents()
+		}
+
+		for _, e := range events {
+			t.Events[e] = true
+		}
+	}
+
+	if _, ok := t.Notifier.(DigestNotifier); ok {
+		if t.digest, err = parseDigestSchedule(q.Get("digest"), q.Get("digest-at")); err != nil {
+			return nil, err
+		}
+	} else if q.Has("digest") {
+		return nil, fmt.Errorf("%s does not support digest", u.Scheme)
+	}
+
+	return t, nil
+}
+
+// Dispatcher sends events to all targets subscribing them.
+type Dispatcher struct {
+	targets []*Target
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time
+	nextDigest map[int]time.Time
+}
+
+func NewDispatcher(rawURLs []string, opts ...Option) (*Dispatcher, error) {
+	d := &Dispatcher{
+		lastSent:   make(map[string]time.Time),
+		nextDigest: make(map[int]time.Time),
+	}
+
+	for _, rawURL := range rawURLs {
+		t, err := Parse(rawURL, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Add(t)
+	}
+
+	return d, nil
+}
+
+func (d *Dispatcher) Add(t *Target) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t.digest != nil {
+		d.nextDigest[len(d.targets)] = t.digest.next(time.Now())
+	}
+
+	d.targets = append(d.targets, t)
+}
+
+func (d *Dispatcher) Targets() []*Target {
+	return d.targets
+}
+
+// throttled reports whether the event has been sent to the target recently, and records it if not.
+func (d *Dispatcher) throttled(i int, e Event) bool {
+	interval, ok := throttles[e.Type]
+	if !ok {
+		return false
+	}
+
+	key := fmt.Sprintf("%d/%s/%s/%s", i, e.Type, e.Group, e.NAS)
+	if e.Type == EventFailed {
+		key += "/" + e.Error
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := d.lastSent[key]; ok && e.Time.Sub(last) < interval {
+		return true
+	}
+
+	d.lastSent[key] = e.Time
+
+	return false
+}
+
+// Notify sends the event to the subscribing targets and returns the joined errors.
+func (d *Dispatcher) Notify(ctx context.Context, e Event) error {
+	if d == nil {
+		return nil
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	var errs []error
+
+	for i, t := range d.targets {
+		if !t.accept(e) || d.throttled(i, e) {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := t.Notify(sendCtx, e)
+		cancel()
+
+		if err != nil {
+			slog.Error("send notification failed", "notifier", t.Name, "event", e.Type, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const serverchanEndpoint = "https://sctapi.ftqq.com"
+
+// serverchan pushes to WeChat by ServerChan Turbo, serverchan://<sendkey>.
+type serverchan struct {
+	url string
+}
+
+func init() {
+	register("serverchan", func(u *url.URL, o *options) (Notifier, error) {
+		if u.Host == "" {
+			return nil, errors.New("missing sendkey")
+		}
+
+		return &serverchan{
+			url: endpointOr(o.endpoint, serverchanEndpoint) + "/" + url.PathEscape(u.Host) + ".send",
+		}, nil
+	})
+}
+
+type serverchanReply struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *serverchan) Notify(ctx context.Context, e Event) error {
+	form := url.Values{
+		"title": {e.Title()},
+		"desp":  {e.Message()},
+	}
+
+	var reply serverchanReply
+	if err := postForm(ctx, s.url, form, &reply); err != nil {
+		return err
+	}
+
+	if reply.Code != 0 {
+		return fmt.Errorf("code %d: %s", reply.Code, reply.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServerChan(t *testing.T) {
+	s := newStandIn(t, `{"code":0,"message":""}`)
+	n := parseNotifier(t, "serverchan://SCT123ABC?endpoint="+s.URL)
+
+	e := testEvent(EventDeployed)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/SCT123ABC.send" || r.ContentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("got %s %s", r.Path, r.ContentType)
+	}
+
+	form, err := url.ParseQuery(string(r.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if form.Get("title") != e.Title() || form.Get("desp") != e.Message() {
+		t.Fatalf("form = %v", form)
+	}
+}
+
+func TestServerChanError(t *testing.T) {
+	s := newStandIn(t, `{"code":40001,"message":"bad pushtoken"}`)
+	n := parseNotifier(t, "serverchan://SCT123ABC?endpoint="+s.URL)
+
+	err := n.Notify(context.Background(), testEvent(EventFailed))
+	if err == nil || !strings.Contains(err.Error(), "bad pushtoken") {
+		t.Fatalf("got %v", err)
+	}
+}
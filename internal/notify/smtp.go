@@ -0,0 +1,230 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	smtpTLSStartTLS = "starttls"
+	smtpTLSImplicit = "implicit"
+	smtpTLSNone     = "none"
+)
+
+// smtpMailer sends email, smtp://<user>:<password>@<host>:<port>?from=<addr>&to=<addr>|<addr>&tls=starttls.
+// tls defaults to implicit on port 465 and starttls on others, none is only for a trusted relay.
+// Only failures and renewals are mailed by default.
+type smtpMailer struct {
+	addr string
+	host string
+	tls  string
+	auth smtp.Auth
+	from *mail.Address
+	to   []*mail.Address
+}
+
+func init() {
+	register("smtp", func(u *url.URL, _ *options) (Notifier, error) {
+		q := u.Query()
+
+		m := &smtpMailer{
+			host: u.Hostname(),
+			tls:  q.Get("tls"),
+		}
+
+		if m.host == "" {
+			return nil, errors.New("missing host")
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "587"
+			if m.tls == smtpTLSImplicit {
+				port = "465"
+			}
+		}
+
+		m.addr = net.JoinHostPort(m.host, port)
+
+		switch m.tls {
+		case "":
+			m.tls = smtpTLSStartTLS
+			if port == "465" {
+				m.tls = smtpTLSImplicit
+			}
+		case smtpTLSStartTLS, smtpTLSImplicit, smtpTLSNone:
+		default:
+			return nil, fmt.Errorf("invalid tls %q", m.tls)
+		}
+
+		if u.User != nil {
+			password, _ := u.User.Password()
+			m.auth = smtp.PlainAuth("", u.User.Username(), password, m.host)
+		}
+
+		var err error
+
+		from := q.Get("from")
+		if from == "" && u.User != nil {
+			from = u.User.Username()
+		}
+
+		if m.from, err = mail.ParseAddress(from); err != nil {
+			return nil, fmt.Errorf("invalid from %q: %w", from, err)
+		}
+
+		// "+" is decoded as space in query, so addresses are separated by space, "|" or ","
+		for _, to := range strings.FieldsFunc(q.Get("to"), func(r rune) bool { return r == '|' || r == ' ' || r == ',' }) {
+			addr, err := mail.ParseAddress(to)
+			if err != nil {
+				return nil, fmt.Errorf("invalid to %q: %w", to, err)
+			}
+
+			m.to = append(m.to, addr)
+		}
+
+		if len(m.to) == 0 {
+			return nil, errors.New("missing to")
+		}
+
+		return m, nil
+	})
+}
+
+// defaultEvents limits the mail to failures and renewals, unless the events are given.
+func (m *smtpMailer) defaultEvents() []EventType {
+	return []EventType{EventFailed, EventIssued}
+}
+
+func (m *smtpMailer) Notify(ctx context.Context, e Event) error {
+	return m.send(ctx, e.Title(), e.Message())
+}
+
+func (m *smtpMailer) NotifyDigest(ctx context.Context, d Digest) error {
+	return m.send(ctx, d.Title(), d.Message())
+}
+
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	dialer := &net.Dialer{}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if m.tls == smtpTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.host}}).DialContext(ctx, "tcp", m.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", m.addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if m.tls == smtpTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (m *smtpMailer) send(ctx context.Context, subject, body string) error {
+	c, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+
+	for _, to := range m.to {
+		if err := c.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(m.message(subject, body)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (m *smtpMailer) message(subject, body string) []byte {
+	to := make([]string, 0, len(m.to))
+	for _, addr := range m.to {
+		to = append(to, addr.String())
+	}
+
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@fnos-acme>\r\n", hex.EncodeToString(id))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"maps"
+	"mime"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// capturedMail is a mail received by the fake smtp server.
+type capturedMail struct {
+	auth string
+	from string
+	rcpt []string
+	data string
+}
+
+// newFakeSMTP serves one smtp session without tls and returns its address and the received mail.
+func newFakeSMTP(t *testing.T) (string, <-chan capturedMail) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan capturedMail, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := textproto.NewConn(conn)
+		defer c.Close()
+
+		var m capturedMail
+
+		_ = c.PrintfLine("220 fake ESMTP")
+
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+
+			verb, arg, _ := strings.Cut(line, " ")
+
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				_ = c.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			case "AUTH":
+				_, initial, _ := strings.Cut(arg, " ")
+				auth, _ := base64.StdEncoding.DecodeString(initial)
+				m.auth = string(auth)
+				_ = c.PrintfLine("235 ok")
+			case "MAIL":
+				m.from = arg
+				_ = c.PrintfLine("250 ok")
+			case "RCPT":
+				m.rcpt = append(m.rcpt, arg)
+				_ = c.PrintfLine("250 ok")
+			case "DATA":
+				_ = c.PrintfLine("354 go ahead")
+
+				data, err := io.ReadAll(c.DotReader())
+				if err != nil {
+					return
+				}
+
+				m.data = string(data)
+				_ = c.PrintfLine("250 ok")
+			case "QUIT":
+				_ = c.PrintfLine("221 bye")
+				mails <- m
+				return
+			default:
+				_ = c.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), mails
+}
+
+func TestSMTP(t *testing.T) {
+	addr, mails := newFakeSMTP(t)
+	n := parseNotifier(t, "smtp://fnos:pass@"+addr+"?tls=none&from=acme@example.com&to=a@example.com|b@example.com")
+
+	e := testEvent(EventFailed)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	m := <-mails
+
+	if m.auth != "\x00fnos\x00pass" {
+		t.Fatalf("auth = %q", m.auth)
+	}
+
+	if m.from != "FROM:<acme@example.com>" {
+		t.Fatalf("from = %s", m.from)
+	}
+
+	if want := []string{"TO:<a@example.com>", "TO:<b@example.com>"}; !slices.Equal(m.rcpt, want) {
+		t.Fatalf("rcpt = %q, want %q", m.rcpt, want)
+	}
+
+	header, body, _ := strings.Cut(m.data, "\n\n")
+	if !strings.Contains(header, "Subject: "+mime.QEncoding.Encode("utf-8", e.Title())+"\n") ||
+		!strings.Contains(header, "To: <a@example.com>, <b@example.com>\n") {
+		t.Fatalf("header = %s", header)
+	}
+
+	if body != e.Message()+"\n" {
+		t.Fatalf("body = %q, want %q", body, e.Message())
+	}
+}
+
+func TestSMTPDefaultEvents(t *testing.T) {
+	for query, want := range map[string][]EventType{
+		"":                 {EventFailed, EventIssued},
+		"&events=deployed": {EventDeployed},
+	} {
+		target, err := Parse("smtp://127.0.0.1?tls=none&from=acme@example.com&to=a@example.com" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := slices.Sorted(maps.Keys(target.Events)); !slices.Equal(got, slices.Sorted(slices.Values(want))) {
+			t.Errorf("%q: events = %v, want %v", query, got, want)
+		}
+	}
+}
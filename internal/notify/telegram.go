@@ -0,0 +1,63 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const telegramEndpoint = "https://api.telegram.org"
+
+// telegram sends by a bot, telegram://<bot-token>@<chat-id>.
+type telegram struct {
+	url    string
+	chatID string
+}
+
+func init() {
+	register("telegram", func(u *url.URL, o *options) (Notifier, error) {
+		// bot token looks like 123456:ABC-DEF, so it is parsed as userinfo.
+		if u.User == nil || u.Host == "" {
+			return nil, errors.New("url must be telegram://<bot-token>@<chat-id>")
+		}
+
+		token := u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			token += ":" + password
+		}
+
+		return &telegram{
+			url:    endpointOr(o.endpoint, telegramEndpoint) + "/bot" + token + "/sendMessage",
+			chatID: u.Host,
+		}, nil
+	})
+}
+
+type telegramReply struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func (t *telegram) Notify(ctx context.Context, e Event) error {
+	body := map[string]any{
+		"chat_id": t.chatID,
+		"text":    e.Title() + "\n" + e.Message(),
+	}
+
+	var reply telegramReply
+	if err := postJSON(ctx, t.url, body, &reply); err != nil {
+		return err
+	}
+
+	if !reply.OK {
+		return fmt.Errorf("send message: %s", reply.Description)
+	}
+
+	return nil
+}
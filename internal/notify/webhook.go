@@ -0,0 +1,45 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// webhook posts the event as json, webhook+https://example.com/hook.
+type webhook struct {
+	url string
+}
+
+func init() {
+	newWebhook := func(u *url.URL, _ *options) (Notifier, error) {
+		target := *u
+		target.Scheme = strings.TrimPrefix(u.Scheme, "webhook+")
+
+		q := target.Query()
+		q.Del("events")
+		q.Del("expiring-days")
+		q.Del("endpoint")
+		target.RawQuery = q.Encode()
+
+		return &webhook{url: target.String()}, nil
+	}
+
+	register("webhook+http", newWebhook)
+	register("webhook+https", newWebhook)
+}
+
+type webhookPayload struct {
+	Event
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+func (w *webhook) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, w.url, webhookPayload{Event: e, Title: e.Title(), Message: e.Message()}, nil)
+}
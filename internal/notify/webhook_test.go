@@ -0,0 +1,33 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	s := newStandIn(t, "")
+	n := parseNotifier(t, "webhook+http://"+strings.TrimPrefix(s.URL, "http://")+"/hook?source=nas&events=failed&expiring-days=3")
+
+	e := testEvent(EventFailed)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/hook" || r.Query.Encode() != "source=nas" {
+		t.Fatalf("got %s?%s, want the options of fnos-acme stripped", r.Path, r.Query.Encode())
+	}
+
+	body := r.jsonBody(t)
+	if body["type"] != string(EventFailed) || body["group"] != e.Group || body["error"] != e.Error ||
+		body["title"] != e.Title() || body["message"] != e.Message() {
+		t.Fatalf("body = %v", body)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const wecomEndpoint = "https://qyapi.weixin.qq.com"
+
+// wecom sends to the group robot of WeCom, wecom://<key>.
+type wecom struct {
+	url string
+}
+
+func init() {
+	register("wecom", func(u *url.URL, o *options) (Notifier, error) {
+		if u.Host == "" {
+			return nil, errors.New("missing robot key")
+		}
+
+		return &wecom{
+			url: endpointOr(o.endpoint, wecomEndpoint) + "/cgi-bin/webhook/send?key=" + url.QueryEscape(u.Host),
+		}, nil
+	})
+}
+
+type wecomReply struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (w *wecom) Notify(ctx context.Context, e Event) error {
+	body := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": fmt.Sprintf("**%s**\n%s", e.Title(), e.Message()),
+		},
+	}
+
+	var reply wecomReply
+	if err := postJSON(ctx, w.url, body, &reply); err != nil {
+		return err
+	}
+
+	if reply.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", reply.ErrCode, reply.ErrMsg)
+	}
+
+	return nil
+}
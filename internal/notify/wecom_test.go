@@ -0,0 +1,44 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWeCom(t *testing.T) {
+	s := newStandIn(t, `{"errcode":0,"errmsg":"ok"}`)
+	n := parseNotifier(t, "wecom://KEY?endpoint="+s.URL)
+
+	e := testEvent(EventFailed)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last(t)
+	if r.Path != "/cgi-bin/webhook/send" || r.Query.Get("key") != "KEY" {
+		t.Fatalf("got %s?%s", r.Path, r.Query.Encode())
+	}
+
+	body := r.jsonBody(t)
+	content := body["markdown"].(map[string]any)["content"].(string)
+
+	if body["msgtype"] != "markdown" || !strings.HasPrefix(content, "**"+e.Title()+"**\n") {
+		t.Fatalf("body = %v", body)
+	}
+}
+
+func TestWeComError(t *testing.T) {
+	s := newStandIn(t, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	n := parseNotifier(t, "wecom://KEY?endpoint="+s.URL)
+
+	err := n.Notify(context.Background(), testEvent(EventFailed))
+	if err == nil || !strings.Contains(err.Error(), "93000") {
+		t.Fatalf("got %v", err)
+	}
+}
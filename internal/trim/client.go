@@ -7,10 +7,12 @@ package trim
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/url"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cospotato/fnos-acme/internal/trim/api/remoteaccess"
@@ -27,8 +29,16 @@ type Client struct {
 	connector func() (*rpc.ClientConn, error)
 	creds     *tlsCreds
 
-	si    string
-	token string
+	// reconnectMu serializes Reconnect, it is not connMu since preflight acquires connMu through services
+	reconnectMu sync.Mutex
+
+	// done is closed by Close to stop keepalive and refuse further reconnects
+	done      chan struct{}
+	closeOnce sync.Once
+
+	si       string
+	token    string
+	loggedIn atomic.Bool
 }
 
 func New(address, typ string, opts ...Opt) (*Client, error) {
@@ -57,10 +67,16 @@ func New(address, typ string, opts ...Opt) (*Client, error) {
 	c := &Client{
 		co:    co,
 		creds: NewTLS(),
+		done:  make(chan struct{}),
 	}
 
 	connector := func() (*rpc.ClientConn, error) {
-		return rpc.DialContext(context.Background(), u.String(), rpc.WithTransportCredentials(c.creds), rpc.WithNotifyHandler(c.notifyHandler))
+		return rpc.DialContext(context.Background(), u.String(),
+			rpc.WithTransportCredentials(c.creds),
+			rpc.WithNotifyHandler(c.notifyHandler),
+			rpc.WithChainUnaryInterceptor(c.co.interceptors...),
+			rpc.WithProtocolDump(c.co.dumpProtocol),
+		)
 	}
 
 	conn, err := connector()
@@ -114,6 +130,7 @@ func (c *Client) authToken(ctx context.Context) error {
 	}
 
 	c.conn.SetBackID(resp.BackId)
+	c.loggedIn.Store(true)
 
 	return nil
 }
@@ -142,21 +159,72 @@ func (c *Client) login(ctx context.Context) error {
 
 	c.conn.SetBackID(resp.BackId)
 	c.token = resp.Token
+	c.loggedIn.Store(true)
 
 	return nil
 }
 
-func (c *Client) Reconnect(ctx context.Context) error {
+// LoggedIn reports whether the client holds a logged in session.
+func (c *Client) LoggedIn() bool {
+	return c.loggedIn.Load()
+}
+
+// Session returns the session id of the login, which is passed to rpc.Session.
+func (c *Client) Session() string {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	return c.si
+}
+
+// Invoke calls the method which is not wrapped by a service.
+func (c *Client) Invoke(ctx context.Context, method string, req, reply any, opts ...rpc.CallOption) error {
 	c.connMu.Lock()
-	defer c.connMu.Unlock()
-	c.conn.Close()
+	conn := c.conn
+	c.connMu.Unlock()
+
+	return conn.Invoke(ctx, method, req, reply, opts...)
+}
+
+func (c *Client) Reconnect(ctx context.Context) (err error) {
+	// keepalive and the notify handler may reconnect at the same time, the conn, session and creds
+	// are replaced by one of them at a time
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	// a closed client is not reconnected, nor reported to the reconnect handler
+	if c.closed() {
+		return errClientClosed
+	}
+
+	if c.co.onReconnect != nil {
+		defer func() {
+			go c.co.onReconnect(err)
+		}()
+	}
+
+	c.loggedIn.Store(false)
 
 	conn, err := c.connector()
 	if err != nil {
 		return err
 	}
 
+	// preflight acquires connMu through services, so swap the conn without holding it
+	c.connMu.Lock()
+	if c.closed() {
+		c.connMu.Unlock()
+		conn.Close()
+
+		return errClientClosed
+	}
+
+	prev := c.conn
 	c.conn = conn
+	c.connMu.Unlock()
+
+	prev.Close()
+
 	return c.preflight(ctx)
 }
 
@@ -166,7 +234,7 @@ func (c *Client) notifyHandler(notify transport.Notify) {
 		slog.Debug("handle token expired/privilege changed")
 
 		go func() {
-			if err := c.Reconnect(context.Background()); err != nil {
+			if err := c.Reconnect(context.Background()); err != nil && !errors.Is(err, errClientClosed) {
 				slog.Error("reconnect failed", "err", err)
 			}
 		}()
@@ -176,22 +244,44 @@ func (c *Client) notifyHandler(notify transport.Notify) {
 }
 
 func (c *Client) keepalive() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
 	for {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if _, err := c.Main().UserService().Active(ctx, &user.ActiveRequest{}); err != nil {
+		if _, err := c.Main().UserService().Active(ctx, &user.ActiveRequest{}); err != nil && !c.closed() {
 			slog.Error("keepalive failed", "err", err)
+			c.loggedIn.Store(false)
 
-			if err := c.Reconnect(ctx); err != nil {
+			if err := c.Reconnect(ctx); err != nil && !errors.Is(err, errClientClosed) {
 				slog.Error("reconnect failed", "err", err)
 			}
 		}
 		cancel()
 
-		time.Sleep(time.Minute)
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
 	}
 }
 
+var errClientClosed = errors.New("client closed")
+
+func (c *Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the connection and stops keepalive, the client is not reconnected afterwards.
 func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
 	c.connMu.Lock()
 	defer c.connMu.Unlock()
 
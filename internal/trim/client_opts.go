@@ -5,9 +5,14 @@
 
 package trim
 
+import "github.com/cospotato/fnos-acme/internal/trim/rpc"
+
 type clientOpts struct {
-	username string
-	password string
+	username     string
+	password     string
+	onReconnect  func(err error)
+	interceptors []rpc.UnaryClientInterceptor
+	dumpProtocol bool
 }
 
 type Opt func(*clientOpts) error
@@ -19,3 +24,28 @@ func WithLogin(username, password string) Opt {
 		return nil
 	}
 }
+
+// WithReconnectHandler sets a callback invoked after each reconnection attempt,
+// err is nil if the client reconnected and logged in again.
+func WithReconnectHandler(h func(err error)) Opt {
+	return func(opts *clientOpts) error {
+		opts.onReconnect = h
+		return nil
+	}
+}
+
+// WithUnaryInterceptor appends interceptors to every call made by the client.
+func WithUnaryInterceptor(interceptors ...rpc.UnaryClientInterceptor) Opt {
+	return func(opts *clientOpts) error {
+		opts.interceptors = append(opts.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithProtocolDump logs the payloads at debug level without redacting passwords, tokens and private keys.
+func WithProtocolDump(enable bool) Opt {
+	return func(opts *clientOpts) error {
+		opts.dumpProtocol = enable
+		return nil
+	}
+}
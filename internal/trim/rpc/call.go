@@ -8,6 +8,10 @@ package rpc
 import "context"
 
 func (cc *ClientConn) Invoke(ctx context.Context, method string, req, reply any, opts ...CallOption) error {
+	if cc.dopts.unaryInt != nil {
+		return cc.dopts.unaryInt(ctx, method, req, reply, cc, invoke, opts...)
+	}
+
 	return invoke(ctx, method, req, reply, cc, opts...)
 }
 
@@ -33,6 +33,8 @@ func NewClient(target string, opts ...DialOption) (conn *ClientConn, err error)
 		o.apply(&cc.dopts)
 	}
 
+	chainUnaryClientInterceptors(cc)
+
 	return cc, nil
 }
 
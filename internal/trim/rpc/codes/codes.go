@@ -8,7 +8,8 @@ package codes
 type Code uint32
 
 const (
-	OK Code = 0
+	OK      Code = 0
+	Unknown Code = 65535
 )
 
 var codeToStr = map[Code]string{
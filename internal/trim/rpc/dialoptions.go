@@ -11,7 +11,9 @@ import (
 )
 
 type dialOptions struct {
-	topts transport.Options
+	topts          transport.Options
+	chainUnaryInts []UnaryClientInterceptor
+	unaryInt       UnaryClientInterceptor
 }
 
 type DialOption interface {
@@ -41,3 +43,17 @@ func WithNotifyHandler(h func(notify transport.Notify)) DialOption {
 		do.topts.NotifyHandler = h
 	})
 }
+
+// WithChainUnaryInterceptor appends the interceptors, the first one is the outermost.
+func WithChainUnaryInterceptor(interceptors ...UnaryClientInterceptor) DialOption {
+	return newFuncDialOption(func(do *dialOptions) {
+		do.chainUnaryInts = append(do.chainUnaryInts, interceptors...)
+	})
+}
+
+// WithProtocolDump disables the redaction of the logged payloads.
+func WithProtocolDump(enable bool) DialOption {
+	return newFuncDialOption(func(do *dialOptions) {
+		do.topts.DumpProtocol = enable
+	})
+}
@@ -6,6 +6,7 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/cospotato/fnos-acme/internal/trim/rpc/codes"
@@ -20,6 +21,14 @@ func (e Error) Error() string {
 	return fmt.Sprintf("rpc error: code = %d desc = %s", e.c, e.m)
 }
 
+func (e Error) Code() codes.Code {
+	return e.c
+}
+
+func (e Error) Message() string {
+	return e.m
+}
+
 func New(c codes.Code, msg string) *Error {
 	return &Error{c: c, m: msg}
 }
@@ -27,3 +36,17 @@ func New(c codes.Code, msg string) *Error {
 func Newf(c codes.Code, format string, a ...any) *Error {
 	return &Error{c: c, m: fmt.Sprintf(format, a...)}
 }
+
+// Code returns the errno carried by err, OK for nil and Unknown for non rpc errors.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.c
+	}
+
+	return codes.Unknown
+}
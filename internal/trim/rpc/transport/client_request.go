@@ -30,6 +30,8 @@ func (s *ClientRequest) Write(data []byte, opts *WriteOptions) (err error) {
 		data = bytes.Join([][]byte{hdr, data[1:]}, []byte(","))
 	}
 
+	s.ct.logger.DebugContext(s.ctx, "send request", "request_id", s.id, "method", s.method, "data", s.ct.payload(s.method, data))
+
 	return s.ct.write(data, opts)
 }
 
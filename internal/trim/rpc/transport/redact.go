@@ -0,0 +1,83 @@
+/*
+ * Copyright 2025 CosPotato Lab.
+ * Author: CosPotato Lin<i@0x233.cn>
+ */
+
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveFields are the fields masked in the logged requests and responses of each method.
+var sensitiveFields = map[string][]string{
+	"user.login":                 {"password", "token", "secret"},
+	"user.authToken":             {"token", "secret"},
+	"appcgi.netsvr.cert.upload":  {"privateKeyBase64"},
+	"appcgi.netsvr.cert.replace": {"privateKeyBase64"},
+}
+
+// commonSensitiveFields are masked in the payloads of all methods, including unknown ones.
+var commonSensitiveFields = []string{"password", "token", "secret", "privateKeyBase64"}
+
+// redact masks the sensitive fields of the json payload of the method,
+// payloads which are not json are replaced by their size since they can not be inspected.
+func redact(method string, data []byte) string {
+	var v any
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+
+	fields := make(map[string]bool)
+	for _, f := range commonSensitiveFields {
+		fields[f] = true
+	}
+
+	for _, f := range sensitiveFields[method] {
+		fields[f] = true
+	}
+
+	out, err := json.Marshal(mask(v, fields))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+
+	return string(out)
+}
+
+func mask(v any, fields map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if fields[k] {
+				v[k] = redacted
+				continue
+			}
+
+			v[k] = mask(e, fields)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = mask(e, fields)
+		}
+	}
+
+	return v
+}
+
+// payload returns the payload to log, it is redacted unless protocol dump is enabled.
+func (t *webSocketClient) payload(method string, data []byte) string {
+	if t.topts.DumpProtocol {
+		return string(data)
+	}
+
+	return redact(method, data)
+}
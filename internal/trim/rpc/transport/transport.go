@@ -42,6 +42,8 @@ type ResponseHeader struct {
 type Options struct {
 	TransportCredentials credentials.TransportCredentials
 	NotifyHandler        func(notify Notify)
+	// DumpProtocol logs the payloads unredacted, they contain passwords and private keys.
+	DumpProtocol bool
 }
 
 type WriteOptions struct {
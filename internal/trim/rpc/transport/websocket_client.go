@@ -9,7 +9,6 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -18,6 +17,7 @@ import (
 	"time"
 
 	"github.com/cospotato/fnos-acme/internal/trim/rpc/codes"
+	rpcerrors "github.com/cospotato/fnos-acme/internal/trim/rpc/errors"
 	"github.com/gorilla/websocket"
 )
 
@@ -84,7 +84,7 @@ func NewWebSocketClient(ctx context.Context, addr string, opts Options) (_ Clien
 		backID:         "0000000000000000",
 		nextID:         1,
 		activeRequests: make(map[string]*ClientRequest),
-		logger:         slog.Default().WithGroup(fmt.Sprintf("[client-transport %s]", addr)),
+		logger:         slog.Default().With("transport", addr),
 		keepalivePong:  make(chan any),
 		keepaliveDone:  make(chan any),
 	}
@@ -175,8 +175,6 @@ func (t *webSocketClient) write(data []byte, opts *WriteOptions) (err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.logger.Debug("write", "data", string(data))
-
 	return t.conn.WriteMessage(websocket.TextMessage, data)
 }
 
@@ -185,8 +183,6 @@ func (t *webSocketClient) handlePing() {
 }
 
 func (t *webSocketClient) handleResponse(data []byte) error {
-	t.logger.Debug("handle response", "data", string(data))
-
 	var hdr ResponseHeader
 	if err := json.Unmarshal(data, &hdr); err != nil {
 		return err
@@ -198,14 +194,18 @@ func (t *webSocketClient) handleResponse(data []byte) error {
 	t.mu.Unlock()
 
 	if !ok {
-		t.logger.Error("Unrecognized reqid", "reqid", hdr.ReqId)
+		t.logger.Debug("handle response", "request_id", hdr.ReqId, "data", t.payload("", data))
+		t.logger.Error("unrecognized reqid", "request_id", hdr.ReqId)
 		return nil
 	}
 
+	t.logger.DebugContext(req.ctx, "handle response", "request_id", hdr.ReqId, "method", req.method, "data", t.payload(req.method, data))
+
 	if hdr.ErrNo == codes.OK {
 		req.data = data
 	} else {
-		req.err = errors.New(hdr.ErrNo.String())
+		req.err = rpcerrors.New(hdr.ErrNo, hdr.ErrNo.String())
+		t.logger.WarnContext(req.ctx, "request failed", "request_id", hdr.ReqId, "method", req.method, "errno", uint32(hdr.ErrNo))
 	}
 
 	close(req.done)
@@ -236,7 +236,11 @@ func (t *webSocketClient) reader() {
 	for {
 		typ, r, err := t.conn.NextReader()
 		if err != nil {
-			errClose = err
+			// the connection has been closed by Close
+			if t.ctx.Err() == nil {
+				errClose = err
+			}
+
 			return
 		}
 